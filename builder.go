@@ -0,0 +1,269 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+// RequestBuilder assembles a Request for one operation, the request is validated for it by Build
+type RequestBuilder struct {
+	op           Operation
+	merchant     *Merchant
+	successURL   *string
+	failURL      *string
+	personalData *PersonalData
+	paymentData  PaymentData
+	method       *PaymentMethod
+	recurrent    *Recurrent
+	splits       []*Split
+}
+
+// NewVerification starts a request for a card verification link, the card token is received in the webhook
+func NewVerification(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationVerification, merchant)
+}
+
+// NewPaymentURL starts a request for a payment page the customer pays the order on
+func NewPaymentURL(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationPaymentURL, merchant)
+}
+
+// NewPayment starts a card or wallet payment request
+func NewPayment(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationPayment, merchant)
+}
+
+// NewHold starts a request holding the amount on the card until it is captured
+func NewHold(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationHold, merchant)
+}
+
+// NewCapture starts a request charging a held order, the whole hold is charged when no amount is set
+func NewCapture(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationCapture, merchant)
+}
+
+// NewRefund starts a refund request, the whole remaining amount is refunded when no amount is set
+func NewRefund(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationRefund, merchant)
+}
+
+// NewVoidHold starts a request releasing a held order without charging it
+func NewVoidHold(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationVoid, merchant)
+}
+
+// NewStatus starts a request for the state of an order
+func NewStatus(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationStatus, merchant)
+}
+
+// NewCredit starts a payout request crediting the recipient card, the recipient is set with Payee.
+// Credit rejects it with ErrCreditNotSupported until the Easypay payout contract is confirmed.
+func NewCredit(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationCredit, merchant)
+}
+
+// NewCreateRecurrent starts a request charging the card now and then on the Schedule
+func NewCreateRecurrent(merchant *Merchant) *RequestBuilder {
+	return newRequestBuilder(OperationCreateRecurrent, merchant)
+}
+
+func newRequestBuilder(op Operation, merchant *Merchant) *RequestBuilder {
+	return &RequestBuilder{
+		op:       op,
+		merchant: merchant,
+	}
+}
+
+// Amount sets the amount and the currency of the payment
+func (b *RequestBuilder) Amount(amount currency.Money) *RequestBuilder {
+	b.paymentData.Amount = amount
+	b.paymentData.Currency = amount.Currency()
+
+	return b
+}
+
+// Order sets the merchant payment ID of the order
+func (b *RequestBuilder) Order(paymentID string) *RequestBuilder {
+	b.paymentData.PaymentID = &paymentID
+
+	return b
+}
+
+// EasypayPaymentID addresses the order by the transaction ID assigned by Easypay
+func (b *RequestBuilder) EasypayPaymentID(id int64) *RequestBuilder {
+	b.paymentData.EasypayPaymentID = &id
+
+	return b
+}
+
+// Description sets the description of the payment shown to the customer
+func (b *RequestBuilder) Description(description string) *RequestBuilder {
+	b.paymentData.Description = description
+
+	return b
+}
+
+// Phone sets the phone of the customer the verification link is created for
+func (b *RequestBuilder) Phone(phone string) *RequestBuilder {
+	// the verification request sends the payment ID as the phone
+	return b.Order(phone)
+}
+
+// Card pays with a card token received from the card verification
+func (b *RequestBuilder) Card(token string) *RequestBuilder {
+	b.method = &PaymentMethod{Card: &Card{Token: &token}}
+	b.paymentData.IsMobile = false
+
+	return b
+}
+
+// CardPan credits the card with the number, for payouts to a card that was not verified
+func (b *RequestBuilder) CardPan(pan string) *RequestBuilder {
+	b.method = &PaymentMethod{Card: &Card{Pan: &pan}}
+	b.paymentData.IsMobile = false
+
+	return b
+}
+
+// ApplePay pays with an Apple Pay payment container
+func (b *RequestBuilder) ApplePay(container string) *RequestBuilder {
+	b.method = &PaymentMethod{AppleContainer: &container}
+	b.paymentData.IsMobile = true
+
+	return b
+}
+
+// GooglePay pays with a Google Pay payment token
+func (b *RequestBuilder) GooglePay(token string) *RequestBuilder {
+	b.method = &PaymentMethod{GoogleToken: &token}
+	b.paymentData.IsMobile = true
+
+	return b
+}
+
+// RefundID sets the caller's ID of a refund, so a repeated refund is sent at most once
+func (b *RequestBuilder) RefundID(id string) *RequestBuilder {
+	b.paymentData.RefundID = &id
+
+	return b
+}
+
+// Webhook sets the URL notified about the payment
+func (b *RequestBuilder) Webhook(url string) *RequestBuilder {
+	b.paymentData.WebhookURL = &url
+
+	return b
+}
+
+// Redirects sets the pages the customer returns to from the payment page,
+// they replace the redirects of the merchant for this request only
+func (b *RequestBuilder) Redirects(successURL string, failURL string) *RequestBuilder {
+	b.successURL, b.failURL = &successURL, &failURL
+
+	return b
+}
+
+// Payee sets the recipient of a payout
+func (b *RequestBuilder) Payee(firstName string, lastName string, taxID string) *RequestBuilder {
+	b.personalData = &PersonalData{FirstName: &firstName, LastName: &lastName, TaxID: &taxID}
+
+	return b
+}
+
+// Schedule sets the charges of a recurrent payment
+func (b *RequestBuilder) Schedule(recurrent Recurrent) *RequestBuilder {
+	b.recurrent = &recurrent
+
+	return b
+}
+
+// Splits divides the payment between several merchants
+func (b *RequestBuilder) Splits(splits ...*Split) *RequestBuilder {
+	b.splits = append(b.splits, splits...)
+
+	return b
+}
+
+// Build returns a new validated request, the builder can be changed and built again afterwards.
+// The request shares no values with the builder, except the merchant and the splits.
+func (b *RequestBuilder) Build() (*Request, error) {
+	paymentData := b.paymentData
+	paymentData.EasypayPaymentID = utils.Clone(b.paymentData.EasypayPaymentID)
+	paymentData.PaymentID = utils.Clone(b.paymentData.PaymentID)
+	paymentData.WebhookURL = utils.Clone(b.paymentData.WebhookURL)
+	paymentData.RefundID = utils.Clone(b.paymentData.RefundID)
+
+	request := &Request{
+		Merchant:    b.merchant,
+		PaymentData: &paymentData,
+		Splits:      append([]*Split(nil), b.splits...),
+	}
+
+	if b.method != nil {
+		request.PaymentMethod = &PaymentMethod{
+			AppleContainer: utils.Clone(b.method.AppleContainer),
+			GoogleToken:    utils.Clone(b.method.GoogleToken),
+		}
+
+		if b.method.Card != nil {
+			request.PaymentMethod.Card = &Card{
+				Name:  b.method.Card.Name,
+				Token: utils.Clone(b.method.Card.Token),
+				Pan:   utils.Clone(b.method.Card.Pan),
+			}
+		}
+	}
+
+	if b.successURL != nil && b.merchant != nil {
+		merchant := *b.merchant
+		merchant.SuccessRedirect, merchant.FailRedirect = *b.successURL, *b.failURL
+		request.Merchant = &merchant
+	}
+
+	if b.personalData != nil {
+		request.PersonalData = &PersonalData{
+			FirstName: utils.Clone(b.personalData.FirstName),
+			LastName:  utils.Clone(b.personalData.LastName),
+			TaxID:     utils.Clone(b.personalData.TaxID),
+		}
+	}
+
+	if b.recurrent != nil {
+		recurrent := *b.recurrent
+		recurrent.DateRun = utils.Clone(b.recurrent.DateRun)
+		recurrent.NotifyURL = utils.Clone(b.recurrent.NotifyURL)
+		request.Recurrent = &recurrent
+	}
+
+	if err := request.Validate(b.op); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
@@ -0,0 +1,218 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+func TestRequestBuilderBuild(t *testing.T) {
+	amount := currency.New(1000, currency.UAH)
+	schedule := Recurrent{CronRule: easypay.Daily(10, 0), DateExpire: time.Now().AddDate(1, 0, 0)}
+
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		want    []error
+	}{
+		{name: "payment", builder: NewPayment(testMerchant).Order("order-1").Amount(amount).Card("token")},
+		{name: "hold with Apple Pay", builder: NewHold(testMerchant).Order("order-1").Amount(amount).ApplePay("container")},
+		{name: "whole capture", builder: NewCapture(testMerchant).Order("order-1")},
+		{name: "refund by Easypay payment ID", builder: NewRefund(testMerchant).EasypayPaymentID(100001).RefundID("refund-1")},
+		{name: "void", builder: NewVoidHold(testMerchant).Order("order-1")},
+		{name: "status", builder: NewStatus(testMerchant).EasypayPaymentID(100001)},
+		{name: "verification", builder: NewVerification(testMerchant).Phone("380501234567")},
+		{name: "payment URL", builder: NewPaymentURL(testMerchant).Order("order-1").Amount(amount).Redirects("https://ok", "https://fail")},
+		{name: "credit", builder: NewCredit(testMerchant).Order("payout-1").Amount(amount).CardPan("4111111111111111").Payee("Taras", "Shevchenko", "1234567890")},
+		{name: "create recurrent", builder: NewCreateRecurrent(testMerchant).Order("order-1").Amount(amount).Card("token").Schedule(schedule)},
+		{
+			name:    "payment without an order, an amount and a payment method",
+			builder: NewPayment(testMerchant),
+			want:    []error{ErrPaymentIDIsNil, ErrAmountIsNotPositive, ErrPaymentMethodIsNil},
+		},
+		{name: "payment without a merchant", builder: NewPayment(nil).Order("order-1").Amount(amount).Card("token"), want: []error{ErrMerchantIsNil}},
+		{name: "capture of a negative amount", builder: NewCapture(testMerchant).Order("order-1").Amount(currency.New(-100, currency.UAH)), want: []error{ErrAmountIsNegative}},
+		{name: "status without an order", builder: NewStatus(testMerchant), want: []error{ErrOrderReferenceIsNil}},
+		{name: "verification without a phone", builder: NewVerification(testMerchant), want: []error{ErrPaymentIDIsNil}},
+		{name: "credit without a payee", builder: NewCredit(testMerchant).Order("payout-1").Amount(amount).CardPan("4111111111111111"), want: []error{ErrPersonalDataIsNil}},
+		{name: "credit without a card", builder: NewCredit(testMerchant).Order("payout-1").Amount(amount).Payee("Taras", "Shevchenko", "1234567890"), want: []error{ErrPaymentInstrumentIsNil}},
+		{name: "create recurrent without a schedule", builder: NewCreateRecurrent(testMerchant).Order("order-1").Amount(amount).Card("token"), want: []error{ErrRecurrentIsNil}},
+		{
+			name:    "payment with invalid splits",
+			builder: NewPayment(testMerchant).Order("order-1").Amount(amount).Card("token").Splits(amountSplit("a", 500)),
+			want:    []error{ErrSplitsMismatch},
+		},
+	}
+
+	for _, tt := range tests {
+		request, err := tt.builder.Build()
+
+		if tt.want == nil {
+			if err != nil || request == nil {
+				t.Errorf("%s: Build() = %v, %v, want a request", tt.name, request, err)
+			}
+			continue
+		}
+
+		if request != nil {
+			t.Errorf("%s: Build() = %+v, want nil", tt.name, request)
+		}
+
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: Build() error = %v, want %v", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestRequestBuilderFields(t *testing.T) {
+	amount := currency.New(2550, currency.UAH)
+	run := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		want    *Request
+	}{
+		{
+			name:    "payment URL",
+			builder: NewPaymentURL(testMerchant).Order("order-1").Amount(amount).Description("basket").Webhook("https://hook").Redirects("https://ok", "https://fail"),
+			want: &Request{
+				Merchant: &Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret", SuccessRedirect: "https://ok", FailRedirect: "https://fail"},
+				PaymentData: &PaymentData{
+					PaymentID:   utils.Ref("order-1"),
+					Amount:      amount,
+					Currency:    currency.UAH,
+					Description: "basket",
+					WebhookURL:  utils.Ref("https://hook"),
+				},
+			},
+		},
+		{
+			name:    "credit",
+			builder: NewCredit(testMerchant).Order("payout-1").Amount(amount).CardPan("4111111111111111").Payee("Taras", "Shevchenko", "1234567890"),
+			want: &Request{
+				Merchant:      testMerchant,
+				PersonalData:  &PersonalData{FirstName: utils.Ref("Taras"), LastName: utils.Ref("Shevchenko"), TaxID: utils.Ref("1234567890")},
+				PaymentData:   &PaymentData{PaymentID: utils.Ref("payout-1"), Amount: amount, Currency: currency.UAH},
+				PaymentMethod: &PaymentMethod{Card: &Card{Pan: utils.Ref("4111111111111111")}},
+			},
+		},
+		{
+			name: "create recurrent",
+			builder: NewCreateRecurrent(testMerchant).Order("order-1").Amount(amount).Card("token").
+				Schedule(Recurrent{CronRule: easypay.Monthly(1, 10, 0), DateRun: &run, DateExpire: run.AddDate(1, 0, 0)}),
+			want: &Request{
+				Merchant:      testMerchant,
+				PaymentData:   &PaymentData{PaymentID: utils.Ref("order-1"), Amount: amount, Currency: currency.UAH},
+				PaymentMethod: &PaymentMethod{Card: &Card{Token: utils.Ref("token")}},
+				Recurrent:     &Recurrent{CronRule: easypay.Monthly(1, 10, 0), DateRun: &run, DateExpire: run.AddDate(1, 0, 0)},
+			},
+		},
+		{
+			name:    "verification",
+			builder: NewVerification(testMerchant).Phone("380501234567"),
+			want: &Request{
+				Merchant:    testMerchant,
+				PaymentData: &PaymentData{PaymentID: utils.Ref("380501234567")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		request, err := tt.builder.Build()
+		if err != nil {
+			t.Errorf("%s: Build() error = %v", tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(request, tt.want) {
+			t.Errorf("%s: Build() = %+v, want %+v", tt.name, request, tt.want)
+		}
+	}
+
+	if testMerchant.SuccessRedirect != "" || testMerchant.FailRedirect != "" {
+		t.Errorf("Redirects changed the merchant to %q and %q", testMerchant.SuccessRedirect, testMerchant.FailRedirect)
+	}
+}
+
+func TestRequestBuilderCopiesOnBuild(t *testing.T) {
+	builder := NewPayment(testMerchant).
+		Order("order-1").
+		Amount(currency.New(1000, currency.UAH)).
+		Card("token").
+		Payee("Taras", "Shevchenko", "1234567890").
+		Splits(amountSplit("a", 1000))
+
+	first, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// changing the built request does not change the builder
+	first.PaymentData.Description = "changed"
+	*first.PaymentData.PaymentID = "changed"
+	*first.PaymentMethod.Card.Token = "changed"
+	first.PaymentMethod.Card = nil
+	*first.PersonalData.FirstName = "changed"
+	first.PersonalData.LastName = nil
+	first.Splits[0] = nil
+
+	second, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if second.PaymentData.Description != "" || *second.PaymentData.PaymentID != "order-1" || *second.PaymentMethod.Card.Token != "token" || second.PersonalData.LastName == nil || second.Splits[0] == nil {
+		t.Errorf("second Build() = %+v, want it unaffected by changes of the first request", second)
+	}
+
+	// changing the builder does not change the built requests
+	builder.
+		Order("order-2").
+		Amount(currency.New(500, currency.UAH)).
+		GooglePay("google").
+		Redirects("https://ok", "https://fail").
+		Payee("Lesya", "Ukrainka", "0987654321").
+		Splits(amountSplit("b", 500))
+
+	if *second.PaymentData.PaymentID != "order-1" || second.PaymentData.Amount.MinorUnits() != 1000 {
+		t.Errorf("second request order = %s %s, want order-1 10.00", *second.PaymentData.PaymentID, second.PaymentData.Amount)
+	}
+	if second.PaymentMethod.Card == nil || second.PaymentMethod.GoogleToken != nil || second.IsMobile() {
+		t.Errorf("second request payment method = %+v, want the card", second.PaymentMethod)
+	}
+	if second.Merchant != testMerchant || *second.PersonalData.FirstName != "Taras" || len(second.Splits) != 1 {
+		t.Errorf("second request = %+v, want it unaffected by changes of the builder", second)
+	}
+}
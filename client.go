@@ -33,6 +33,7 @@ import (
 	"time"
 
 	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
 	"github.com/stremovskyy/go-easypay/easypay"
 	"github.com/stremovskyy/go-easypay/internal/http"
 	"github.com/stremovskyy/go-easypay/log"
@@ -41,7 +42,8 @@ import (
 
 type client struct {
 	easypayClient *http.Client
-	app           *easypay.App
+	apps          *appCache
+	idempotency   IdempotencyStore
 }
 
 func (c *client) SetLogLevel(levelDebug log.Level) {
@@ -51,18 +53,21 @@ func (c *client) SetLogLevel(levelDebug log.Level) {
 func NewDefaultClient() Easypay {
 	return &client{
 		easypayClient: http.NewClient(http.DefaultOptions()),
+		apps:          newAppCache(),
 	}
 }
 
 func NewClientWithRecorder(rec recorder.Recorder) Easypay {
 	return &client{
 		easypayClient: http.NewClient(http.DefaultOptions()).WithRecorder(rec),
+		apps:          newAppCache(),
 	}
 }
 
 func NewClient(options ...Option) Easypay {
 	c := &client{
 		easypayClient: http.NewClient(http.DefaultOptions()),
+		apps:          newAppCache(),
 	}
 
 	for _, option := range options {
@@ -73,309 +78,594 @@ func NewClient(options ...Option) Easypay {
 }
 
 func (c *client) VerificationLink(request *Request) (*url.URL, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
-	}
-
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
-		}
-	}
+	return c.VerificationLinkCtx(context.Background(), request)
+}
 
-	pageID, err := c.createPageID(request)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
-	}
-
-	createTokenRequest := easypay.NewRequest(
-		consts.CardTokenCreateURL,
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithPhone(request.GetPaymentID()),
-		easypay.WithRedirects(request.GetRedirects()),
-		easypay.WithWebhook(request.GetWebhookURL()),
+func (c *client) VerificationLinkCtx(ctx context.Context, request *Request) (*url.URL, error) {
+	if err := request.Validate(OperationVerification); err != nil {
+		return nil, err
+	}
+
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			createTokenRequest := easypay.NewRequest(
+				consts.CardTokenCreatePath,
+				easypay.WithOperation(OperationVerification),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithPhone(request.GetPaymentID()),
+				easypay.WithRedirects(request.GetRedirects()),
+				easypay.WithWebhook(request.GetWebhookURL()),
+				easypay.WithRetry(),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, createTokenRequest)
+
+			return err
+		},
 	)
-
-	apiResponse, err := c.easypayClient.Api(createTokenRequest)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get API response: %v", err)
+		return nil, fmt.Errorf("cannot get API response: %w", err)
 	}
 
 	u, err := url.Parse(apiResponse.ForwardUrl)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse URL: %v", err)
+		return nil, fmt.Errorf("cannot parse URL: %w", err)
 	}
 
 	return u, nil
 }
 
 func (c *client) Status(request *Request) (*easypay.Response, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
-	}
+	return c.StatusCtx(context.Background(), request)
+}
 
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
-		}
+func (c *client) StatusCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationStatus); err != nil {
+		return nil, err
+	}
+
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			statusRequest := easypay.NewRequest(
+				consts.CheckOrderStatePath,
+				easypay.WithOperation(OperationStatus),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithTransactionID(request.GetTransactionID()),
+				easypay.WithRootOrderID(request.GetPaymentID()),
+				easypay.WithoutError(),
+				easypay.WithRetry(),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, statusRequest)
+			if err != nil {
+				return err
+			}
+
+			// errors are returned to the caller inside the response, except a rejected App that must be refreshed
+			if responseErr := c.easypayClient.ResponseError(apiResponse); easypay.IsAppRejected(responseErr) {
+				return responseErr
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting status: %w", err)
 	}
 
-	pageID, err := c.createPageID(request)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
-	}
-
-	cancelRequest := easypay.NewRequest(
-		consts.CheckOrderStateURL,
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
-		easypay.WithTransactionID(request.GetTransactionID()),
-		easypay.WithRootOrderID(request.GetPaymentID()),
-		easypay.WithoutError(),
-	)
+	return apiResponse, nil
+}
 
-	apiResponse, err := c.easypayClient.Api(cancelRequest)
+func (c *client) OrderState(request *Request) (*easypay.PaymentStatusResponse, error) {
+	return c.OrderStateCtx(context.Background(), request)
+}
+
+func (c *client) OrderStateCtx(ctx context.Context, request *Request) (*easypay.PaymentStatusResponse, error) {
+	if err := request.Validate(OperationStatus); err != nil {
+		return nil, err
+	}
+
+	statusResponse := &easypay.PaymentStatusResponse{}
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			orderStateRequest := easypay.NewRequest(
+				consts.CheckOrderStatePath,
+				easypay.WithOperation(OperationStatus),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithTransactionID(request.GetTransactionID()),
+				easypay.WithRootOrderID(request.GetPaymentID()),
+				easypay.WithRetry(),
+			)
+
+			return c.easypayClient.TypedApi(ctx, orderStateRequest, statusResponse)
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating payment: %v", err)
+		return nil, fmt.Errorf("error while getting order state: %w", err)
 	}
 
-	return apiResponse, nil
+	return statusResponse, nil
 }
 
-func (c *client) PaymentURL(request *Request) (*easypay.Response, error) {
-	panic("implement me")
+func (c *client) PaymentURL(request *Request) (*easypay.PaymentURLResponse, error) {
+	return c.PaymentURLCtx(context.Background(), request)
 }
 
-func (c *client) Payment(request *Request) (*easypay.Response, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
+func (c *client) PaymentURLCtx(ctx context.Context, request *Request) (*easypay.PaymentURLResponse, error) {
+	if err := request.Validate(OperationPaymentURL); err != nil {
+		return nil, err
+	}
+
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			paymentURLRequest := easypay.NewRequest(
+				consts.CreateOrderPath,
+				easypay.WithOperation(OperationPaymentURL),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithOrderID(request.GetPaymentID()),
+				easypay.WithIdempotencyKey(request.GetPaymentID()),
+				easypay.WithAmount(request.GetAmount()),
+				easypay.WithCurrency(request.GetCurrency()),
+				easypay.WithDescription(request.GetDescription()),
+				easypay.WithServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithOneTimePayment(true),
+				easypay.WithRedirects(request.GetRedirects()),
+				easypay.WithWebhook(request.GetWebhookURL()),
+				easypay.WithBankingDetails(request.GetBankingDetails()),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, paymentURLRequest)
+
+			return err
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating payment URL: %w", err)
 	}
 
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
-		}
+	if apiResponse.ForwardUrl == "" {
+		return nil, fmt.Errorf("payment URL is empty in API response")
 	}
 
-	pageID, err := c.createPageID(request)
+	u, err := url.Parse(apiResponse.ForwardUrl)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
+		return nil, fmt.Errorf("cannot parse URL: %w", err)
 	}
 
-	requestOptions := []func(*easypay.Request){
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithAdditionalWebhook(request.GetWebhookURL()),
-		easypay.WithOrderID(request.GetPaymentID()),
-		easypay.WithAmount(request.GetAmount()),
-		easypay.WithDescription(request.GetDescription()),
-		easypay.WithServiceKey(request.Merchant.GetServiceKey()),
-		easypay.WithOneTimePayment(true),
-		easypay.WithBankingDetails(request.GetBankingDetails()),
-	}
+	return &easypay.PaymentURLResponse{
+		URL:           u,
+		TransactionID: apiResponse.TransactionId,
+		OrderID:       request.GetPaymentID(),
+		PaymentState:  apiResponse.PaymentState,
+		Response:      apiResponse,
+	}, nil
+}
 
-	if request.IsMobile() {
-		if request.IsApplePay() {
-			requestOptions = append(requestOptions, easypay.WithApplePayContainer(request.GetAppleContainer()))
-			requestOptions = append(requestOptions, easypay.WithPaymentInstrumentMerchantID(request.GetAppleMerchantID()))
-		} else {
-			requestOptions = append(requestOptions, easypay.WithGooglePayToken(request.GetGoogleToken()))
-		}
-	} else {
-		requestOptions = append(requestOptions, easypay.WithCardToken(request.GetCardToken()))
-		requestOptions = append(requestOptions, easypay.WithCardTokenID(request.GetCardTokenID()))
+func (c *client) Payment(request *Request) (*easypay.Response, error) {
+	return c.PaymentCtx(context.Background(), request)
+}
+
+func (c *client) PaymentCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationPayment); err != nil {
+		return nil, err
 	}
 
-	paymentRequest := easypay.NewRequest(
-		consts.CreateOrderURL,
-		requestOptions...,
-	)
+	return c.idempotent(ctx, newOperationRecord(OperationPayment, request), request, nil, c.sendPayment)
+}
 
-	apiResponse, err := c.easypayClient.Api(paymentRequest)
+func (c *client) sendPayment(ctx context.Context, request *Request) (*easypay.Response, error) {
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			requestOptions := []func(*easypay.Request){
+				easypay.WithOperation(OperationPayment),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithAdditionalWebhook(request.GetWebhookURL()),
+				easypay.WithOrderID(request.GetPaymentID()),
+				easypay.WithIdempotencyKey(request.GetPaymentID()),
+				easypay.WithAmount(request.GetAmount()),
+				easypay.WithCurrency(request.GetCurrency()),
+				easypay.WithDescription(request.GetDescription()),
+				easypay.WithServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithOneTimePayment(true),
+				easypay.WithBankingDetails(request.GetBankingDetails()),
+			}
+
+			requestOptions = append(requestOptions, paymentInstrumentOptions(request)...)
+
+			if splitting := request.GetSplitting(); splitting != nil {
+				requestOptions = append(requestOptions, easypay.WithSplitting(splitting))
+			}
+
+			paymentRequest := easypay.NewRequest(
+				consts.CreateOrderPath,
+				requestOptions...,
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, paymentRequest)
+
+			return err
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating payment: %v", err)
+		return nil, fmt.Errorf("error while creating payment: %w", err)
 	}
 
 	return apiResponse, nil
 }
 
 func (c *client) Hold(request *Request) (*easypay.Response, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
-	}
+	return c.HoldCtx(context.Background(), request)
+}
 
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
-		}
+func (c *client) HoldCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationHold); err != nil {
+		return nil, err
 	}
 
-	pageID, err := c.createPageID(request)
+	return c.idempotent(ctx, newOperationRecord(OperationHold, request), request, nil, c.sendHold)
+}
+
+func (c *client) sendHold(ctx context.Context, request *Request) (*easypay.Response, error) {
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			requestOptions := []func(*easypay.Request){
+				easypay.WithPaymentOperation(consts.PaymentOperationPaymentHold),
+				easypay.WithOperation(OperationHold),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithAdditionalWebhook(request.GetWebhookURL()),
+				easypay.WithOrderID(request.GetPaymentID()),
+				easypay.WithIdempotencyKey(request.GetPaymentID()),
+				easypay.WithAmount(request.GetAmount()),
+				easypay.WithCurrency(request.GetCurrency()),
+				easypay.WithDescription(request.GetDescription()),
+				easypay.WithServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithOneTimePayment(true),
+				easypay.WithBankingDetails(request.GetBankingDetails()),
+			}
+
+			requestOptions = append(requestOptions, paymentInstrumentOptions(request)...)
+
+			if splitting := request.GetSplitting(); splitting != nil {
+				requestOptions = append(requestOptions, easypay.WithSplitting(splitting))
+			}
+
+			holdRequest := easypay.NewRequest(
+				consts.CreateOrderPath,
+				requestOptions...,
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, holdRequest)
+
+			return err
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
+		return nil, fmt.Errorf("error while creating hold: %w", err)
 	}
 
-	requestOptions := []func(*easypay.Request){
-		easypay.WithPaymentOperation(consts.PaymentOperationPaymentHold),
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithAdditionalWebhook(request.GetWebhookURL()),
-		easypay.WithOrderID(request.GetPaymentID()),
-		easypay.WithAmount(request.GetAmount()),
-		easypay.WithDescription(request.GetDescription()),
-		easypay.WithServiceKey(request.Merchant.GetServiceKey()),
-		easypay.WithOneTimePayment(true),
-		easypay.WithBankingDetails(request.GetBankingDetails()),
-	}
+	return apiResponse, nil
+}
 
-	if request.IsMobile() {
-		if request.IsApplePay() {
-			requestOptions = append(requestOptions, easypay.WithApplePayContainer(request.GetAppleContainer()))
-			requestOptions = append(requestOptions, easypay.WithPaymentInstrumentMerchantID(request.GetAppleMerchantID()))
-		} else {
-			requestOptions = append(requestOptions, easypay.WithGooglePayToken(request.GetGoogleToken()))
-		}
-	} else {
-		requestOptions = append(requestOptions, easypay.WithCardToken(request.GetCardToken()))
-		requestOptions = append(requestOptions, easypay.WithCardTokenID(request.GetCardTokenID()))
+func (c *client) Capture(request *Request) (*easypay.Response, error) {
+	return c.CaptureCtx(context.Background(), request)
+}
+
+func (c *client) CaptureCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationCapture); err != nil {
+		return nil, err
 	}
 
-	holdRequest := easypay.NewRequest(
-		consts.CreateOrderURL,
-		requestOptions...,
-	)
+	return c.idempotent(ctx, newOperationRecord(OperationCapture, request), request, nil, c.sendCapture)
+}
 
-	apiResponse, err := c.easypayClient.Api(holdRequest)
+func (c *client) sendCapture(ctx context.Context, request *Request) (*easypay.Response, error) {
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			captureRequest := easypay.NewRequest(
+				consts.UnHoldPath,
+				easypay.WithOperation(OperationCapture),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithTransactionID(request.GetTransactionID()),
+				easypay.WithRootAmount(request.GetAmount()),
+				easypay.WithRootOrderID(request.GetPaymentID()),
+				easypay.WithWebhook(request.GetWebhookURL()),
+				easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, captureRequest)
+
+			return err
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating payment: %v", err)
+		return nil, fmt.Errorf("error while capturing payment: %w", err)
 	}
 
 	return apiResponse, nil
 }
 
-func (c *client) Capture(request *Request) (*easypay.Response, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
+func (c *client) Refund(request *Request) (*easypay.CancelPaymentResponse, error) {
+	return c.RefundCtx(context.Background(), request)
+}
+
+func (c *client) RefundCtx(ctx context.Context, request *Request) (*easypay.CancelPaymentResponse, error) {
+	if err := request.Validate(OperationRefund); err != nil {
+		return nil, err
+	}
+
+	state, err := c.OrderStateCtx(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get refundable amount: %w", err)
+	}
+
+	ledger, err := state.RefundLedger()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get refundable amount: %w", err)
+	}
+
+	remaining, err := ledger.Remaining()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get refundable amount: %w", err)
 	}
 
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
+	// a zero amount refunds everything that is left
+	amount := request.GetAmount()
+	if amount.IsZero() {
+		amount = remaining
+	}
+
+	record := newRefundRecord(request, amount, len(state.Refunds()))
+
+	// a refund repeated after it took effect is resolved before the remaining amount is checked
+	check := func() error {
+		if state.IsHeld() {
+			return fmt.Errorf("%w: order %s", ErrOrderIsHeld, state.OrderID)
+		}
+
+		cmp, err := amount.Compare(remaining)
 		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
+			return fmt.Errorf("%w: %w", ErrCurrencyMismatch, err)
+		}
+
+		if amount.IsZero() || cmp > 0 {
+			return fmt.Errorf("%w: requested %s, refundable %s", ErrRefundExceedsRemaining, amount, remaining)
 		}
+
+		return nil
 	}
 
-	pageID, err := c.createPageID(request)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
-	}
-
-	CaptureRequest := easypay.NewRequest(
-		consts.UnHoldURL,
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithTransactionID(request.GetTransactionID()),
-		easypay.WithRootAmount(request.GetAmount()),
-		easypay.WithRootOrderID(request.GetPaymentID()),
-		easypay.WithWebhook(request.GetWebhookURL()),
-		easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+	response, err := c.idempotent(
+		ctx, record, request, check, func(ctx context.Context, request *Request) (*easypay.Response, error) {
+			return c.sendRefund(ctx, request, amount)
+		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	refundResponse := easypay.NewCancelPaymentResponse(response)
+	ledger.Record(refundResponse.RefundTransactionID, amount)
+	refundResponse.Ledger = ledger
+
+	return refundResponse, nil
+}
 
-	apiResponse, err := c.easypayClient.Api(CaptureRequest)
+// sendRefund cancels the amount of the order, the amount is resolved by RefundCtx and never zero
+func (c *client) sendRefund(ctx context.Context, request *Request, amount currency.Money) (*easypay.Response, error) {
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			cancelRequest := easypay.NewRequest(
+				consts.CancelOrderPath,
+				easypay.WithOperation(OperationRefund),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithTransactionID(request.GetTransactionID()),
+				easypay.WithRootOrderID(request.GetPaymentID()),
+				easypay.WithRootAmount(amount),
+				easypay.WithWebhook(request.GetWebhookURL()),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, cancelRequest)
+
+			return err
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating payment: %v", err)
+		return nil, fmt.Errorf("error while refunding payment: %w", err)
 	}
 
 	return apiResponse, nil
 }
 
-func (c *client) Refund(request *Request) (*easypay.Response, error) {
-	if request == nil {
-		return nil, ErrRequestIsNil
+func (c *client) VoidHold(request *Request) (*easypay.VoidHoldResponse, error) {
+	return c.VoidHoldCtx(context.Background(), request)
+}
+
+// VoidHoldCtx releases the whole amount of a held order, unlike Refund it never charges the card
+// and fails with ErrOrderIsNotHeld for orders that are not held
+func (c *client) VoidHoldCtx(ctx context.Context, request *Request) (*easypay.VoidHoldResponse, error) {
+	if err := request.Validate(OperationVoid); err != nil {
+		return nil, err
 	}
 
-	if c.app == nil || !c.app.IsValid() {
-		err := c.createApp(request.Merchant)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create App: %v", err)
+	state, err := c.OrderStateCtx(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get order state: %w", err)
+	}
+
+	// a void repeated after it took effect is resolved before the order is checked to be held
+	check := func() error {
+		if !state.IsHeld() {
+			return fmt.Errorf("%w: order %s is %s", ErrOrderIsNotHeld, state.OrderID, state.PaymentState)
 		}
+
+		return nil
 	}
 
-	pageID, err := c.createPageID(request)
+	response, err := c.idempotent(ctx, newOperationRecord(OperationVoid, request), request, check, c.sendVoidHold)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create Page ID: %v", err)
-	}
-
-	cancelRequest := easypay.NewRequest(
-		consts.CancelOrderURL,
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
-		easypay.WithPageIDHeader(pageID),
-		easypay.WithSecretKey(request.Merchant.GetSecretKey()),
-		easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
-		easypay.WithTransactionID(request.GetTransactionID()),
-		easypay.WithRootOrderID(request.GetPaymentID()),
-		easypay.WithRootAmount(request.GetAmount()),
-		easypay.WithWebhook(request.GetWebhookURL()),
-	)
+		return nil, err
+	}
 
-	apiResponse, err := c.easypayClient.Api(cancelRequest)
+	return easypay.NewVoidHoldResponse(response, state.Amount), nil
+}
+
+func (c *client) sendVoidHold(ctx context.Context, request *Request) (*easypay.Response, error) {
+	var apiResponse *easypay.Response
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			// no amount is sent, cancelling a hold releases all of it
+			voidRequest := easypay.NewRequest(
+				consts.CancelOrderPath,
+				easypay.WithOperation(OperationVoid),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithRootServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithTransactionID(request.GetTransactionID()),
+				easypay.WithRootOrderID(request.GetPaymentID()),
+				easypay.WithWebhook(request.GetWebhookURL()),
+			)
+
+			var err error
+			apiResponse, err = c.easypayClient.Api(ctx, voidRequest)
+
+			return err
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("error while creating payment: %v", err)
+		return nil, fmt.Errorf("error while voiding hold: %w", err)
 	}
 
 	return apiResponse, nil
 }
 
 func (c *client) Credit(request *Request) (*easypay.Response, error) {
-	panic("implement me")
+	return c.CreditCtx(context.Background(), request)
 }
 
-func (c *client) createApp(merchant *Merchant) error {
-	createAppRequest := easypay.NewRequest(
-		consts.CreateAppURL,
-		easypay.WithPartnerKeyHeader(merchant.getPartnerKey()),
-	)
-
-	response, err := c.easypayClient.NotRecordedApi(createAppRequest)
-	if err != nil {
-		return fmt.Errorf("cannot get App response: %v", err)
+// CreditCtx validates the payout request but sends nothing, the Easypay payout contract is not confirmed yet
+func (c *client) CreditCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationCredit); err != nil {
+		return nil, err
 	}
 
-	c.app = response.App()
+	return nil, ErrCreditNotSupported
+}
 
-	return nil
+func (c *client) CreateRecurrent(request *Request) (*easypay.Response, error) {
+	return c.CreateRecurrentCtx(context.Background(), request)
 }
 
-func (c *client) createPageID(request *Request) (*string, error) {
-	pageRequest := easypay.NewRequest(
-		consts.CreatePageURL,
-		easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
-		easypay.WithAppIDHeader(c.app.AppID()),
+// CreateRecurrentCtx charges the first payment of the schedule with createOrder, the ID of the recurrent
+// payment is reported to the webhook with the notifications of its charges
+func (c *client) CreateRecurrentCtx(ctx context.Context, request *Request) (*easypay.Response, error) {
+	if err := request.Validate(OperationCreateRecurrent); err != nil {
+		return nil, err
+	}
+
+	recurrentResponse := &easypay.Response{}
+
+	err := c.withSession(
+		ctx, request.Merchant, func(appID string, pageID *string) error {
+			recurrentRequest := easypay.NewRequest(
+				consts.CreateOrderPath,
+				easypay.WithOperation(OperationCreateRecurrent),
+				easypay.WithPartnerKeyHeader(request.Merchant.getPartnerKey()),
+				easypay.WithAppIDHeader(appID),
+				easypay.WithPageIDHeader(pageID),
+				easypay.WithSecretKey(request.Merchant.GetSecretKey()),
+				easypay.WithOrderID(request.GetPaymentID()),
+				easypay.WithIdempotencyKey(request.GetPaymentID()),
+				easypay.WithAmount(request.GetAmount()),
+				easypay.WithCurrency(request.GetCurrency()),
+				easypay.WithDescription(request.GetDescription()),
+				easypay.WithServiceKey(request.Merchant.GetServiceKey()),
+				easypay.WithBankingDetails(request.GetBankingDetails()),
+				easypay.WithCardToken(request.GetCardToken()),
+				easypay.WithCardTokenID(request.GetCardTokenID()),
+				easypay.WithRecurrent(request.GetRecurrent()),
+			)
+
+			return c.easypayClient.TypedApi(ctx, recurrentRequest, recurrentResponse)
+		},
 	)
-
-	response, err := c.easypayClient.NotRecordedApi(pageRequest)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get Page response: %v", err)
+		return nil, fmt.Errorf("error while creating recurrent: %w", err)
+	}
+
+	return recurrentResponse, nil
+}
+
+// paymentInstrumentOptions selects the wallet or card token instrument of the request
+func paymentInstrumentOptions(request *Request) []func(*easypay.Request) {
+	if request.IsMobile() {
+		if request.IsApplePay() {
+			return []func(*easypay.Request){
+				easypay.WithApplePayContainer(request.GetAppleContainer()),
+				easypay.WithPaymentInstrumentMerchantID(request.GetAppleMerchantID()),
+			}
+		}
+
+		return []func(*easypay.Request){
+			easypay.WithGooglePayToken(request.GetGoogleToken()),
+		}
 	}
 
-	return response.PageId, nil
+	return []func(*easypay.Request){
+		easypay.WithCardToken(request.GetCardToken()),
+		easypay.WithCardTokenID(request.GetCardTokenID()),
+	}
 }
 
 func (c *client) GetRecordedExchange(ctx context.Context, requestID string) (*easypay.RecordedExchange, error) {
@@ -474,7 +764,7 @@ func (c *client) GetExchangesByOrderID(ctx context.Context, orderID string) ([]*
 		exchange, err := c.GetRecordedExchange(ctx, requestID)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("failed to get exchange %s: %w", requestID, err))
-			log.NewLogger("easypay").Error("failed to get exchange", "requestID", requestID, "error", err)
+			log.NewLogger("easypay").Error("failed to get exchange %s: %v", requestID, err)
 			continue
 		}
 		exchanges = append(exchanges, exchange)
@@ -520,7 +810,7 @@ func (c *client) GetExchangesByTransactionID(ctx context.Context, transactionID
 		exchange, err := c.GetRecordedExchange(ctx, requestID)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("failed to get exchange %s: %w", requestID, err))
-			log.NewLogger("easypay").Error("failed to get exchange", "requestID", requestID, "error", err)
+			log.NewLogger("easypay").Error("failed to get exchange %s: %v", requestID, err)
 			continue
 		}
 		exchanges = append(exchanges, exchange)
@@ -0,0 +1,314 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+func TestRefundSendsResolvedAmount(t *testing.T) {
+	var sent json.RawMessage
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed",
+					"paymentsList": [{"refundTransactionId": 2, "amount": 3.00, "paymentState": "Refunded"}]}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Amount json.RawMessage `json:"amount"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				sent = body.Amount
+
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "refundTransactionId": 3, "paymentState": "accepted"}`))
+			},
+		},
+	)
+
+	request, err := NewRefund(&Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret"}).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	response, err := NewClient(WithBaseURL(server.URL)).Refund(request)
+	if err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+
+	if string(sent) != "7.00" {
+		t.Errorf("cancelOrder amount = %s, want the remaining 7.00", sent)
+	}
+
+	if refunded, err := response.Ledger.Refunded(); err != nil || refunded.MinorUnits() != 1000 {
+		t.Errorf("ledger refunded = %s, %v, want 10.00", refunded, err)
+	}
+}
+
+func TestRefundHeldOrder(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "paymenthold"}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("a held order must not be cancelled by Refund")
+			},
+		},
+	)
+
+	request, err := NewRefund(&Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret"}).
+		Order("order-1").
+		Amount(currency.New(100, currency.UAH)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	if _, err = NewClient(WithBaseURL(server.URL)).Refund(request); !errors.Is(err, ErrOrderIsHeld) {
+		t.Errorf("Refund error = %v, want ErrOrderIsHeld", err)
+	}
+}
+
+func TestPaymentURLRejectsMissingURL(t *testing.T) {
+	for name, response := range map[string]string{
+		"empty":   `{"orderId": "order-1", "transactionId": 1, "paymentState": "Pending"}`,
+		"invalid": `{"orderId": "order-1", "transactionId": 1, "paymentState": "Pending", "forwardUrl": "://checkout"}`,
+	} {
+		server := newStubServer(
+			t, map[string]http.HandlerFunc{
+				consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+					_, _ = w.Write([]byte(response))
+				},
+			},
+		)
+
+		request := &Request{
+			Merchant:    testMerchant,
+			PaymentData: &PaymentData{PaymentID: utils.Ref("order-1"), Amount: currency.New(1000, currency.UAH)},
+		}
+
+		if _, err := NewClient(WithBaseURL(server.URL)).PaymentURL(request); err == nil {
+			t.Errorf("%s: PaymentURL error = nil, want the forward URL rejected", name)
+		}
+	}
+}
+
+func TestCaptureWithoutAmount(t *testing.T) {
+	var sent map[string]json.RawMessage
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.UnHoldPath: func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&sent)
+
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`))
+			},
+		},
+	)
+
+	request, err := NewCapture(testMerchant).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	if _, err = NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil)).Capture(request); err != nil {
+		t.Fatalf("Capture error = %v", err)
+	}
+
+	if amount, ok := sent["amount"]; ok {
+		t.Errorf("unHoldOrder amount = %s, want it left out to capture the whole hold", amount)
+	}
+	if string(sent["orderId"]) != `"order-1"` {
+		t.Errorf("unHoldOrder orderId = %s, want order-1", sent["orderId"])
+	}
+}
+
+func TestPaymentSendsCurrency(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		amount currency.Money
+		want   string
+	}{
+		{name: "hryvnia", amount: currency.New(1000, currency.UAH), want: `"UAH"`},
+		{name: "dollar", amount: currency.New(1000, currency.USD), want: `"USD"`},
+	} {
+		var sent struct {
+			Order struct {
+				Amount   json.RawMessage `json:"amount"`
+				Currency json.RawMessage `json:"currency"`
+			} `json:"order"`
+		}
+
+		server := newStubServer(
+			t, map[string]http.HandlerFunc{
+				consts.CreateOrderPath: func(w http.ResponseWriter, r *http.Request) {
+					_ = json.NewDecoder(r.Body).Decode(&sent)
+
+					_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`))
+				},
+			},
+		)
+
+		request, err := NewPayment(testMerchant).Order("order-1").Amount(tt.amount).Card("token").Build()
+		if err != nil {
+			t.Fatalf("%s: Build error = %v", tt.name, err)
+		}
+
+		if _, err = NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil)).Payment(request); err != nil {
+			t.Fatalf("%s: Payment error = %v", tt.name, err)
+		}
+
+		if string(sent.Order.Currency) != tt.want {
+			t.Errorf("%s: createOrder currency = %s, want %s", tt.name, sent.Order.Currency, tt.want)
+		}
+		if string(sent.Order.Amount) != "10.00" {
+			t.Errorf("%s: createOrder amount = %s, want 10.00", tt.name, sent.Order.Amount)
+		}
+	}
+}
+
+func TestErrorCodesRenewApp(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		options   []Option
+		wantCalls int
+	}{
+		{name: "unknown code", wantCalls: 1},
+		{name: "code mapped to ErrAppExpired", options: []Option{WithErrorCodes(map[string]error{"session-gone": easypay.ErrAppExpired})}, wantCalls: 2},
+	} {
+		calls := 0
+		server := newStubServer(
+			t, map[string]http.HandlerFunc{
+				consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+					if calls++; calls == 1 {
+						_, _ = w.Write([]byte(`{"error": {"errorCode": "SESSION_GONE"}}`))
+						return
+					}
+
+					_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`))
+				},
+			},
+		)
+
+		options := append([]Option{WithBaseURL(server.URL), WithRetryPolicy(nil)}, tt.options...)
+		_, err := NewClient(options...).Payment(newIdempotentPayment(t))
+
+		if calls != tt.wantCalls {
+			t.Errorf("%s: createOrder called %d times, want %d", tt.name, calls, tt.wantCalls)
+		}
+		if (err == nil) != (tt.wantCalls == 2) {
+			t.Errorf("%s: Payment error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestAPIVersionAndBaseURLPerClient(t *testing.T) {
+	type sent struct {
+		host    string
+		version string
+	}
+
+	newServer := func(sentRequests *[]sent, mu *sync.Mutex) string {
+		record := func(w http.ResponseWriter, r *http.Request, body string) {
+			mu.Lock()
+			*sentRequests = append(*sentRequests, sent{host: r.Host, version: r.Header.Get("Api-Version")})
+			mu.Unlock()
+
+			_, _ = w.Write([]byte(body))
+		}
+
+		return newStubServer(
+			t, map[string]http.HandlerFunc{
+				consts.CreateAppPath: func(w http.ResponseWriter, r *http.Request) {
+					record(w, r, `{"appId": "app"}`)
+				},
+				consts.CreateOrderPath: func(w http.ResponseWriter, r *http.Request) {
+					record(w, r, `{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`)
+				},
+			},
+		).URL
+	}
+
+	type clientCase struct {
+		name        string
+		wantVersion string
+		serverURL   string
+		sent        []sent
+		client      Easypay
+	}
+
+	// both clients exist at once, so one's settings cannot leak into the other
+	var mu sync.Mutex
+	cases := []*clientCase{
+		{name: "default version", wantVersion: consts.ApiVersion},
+		{name: "configured version", wantVersion: "9.99"},
+	}
+	for _, tt := range cases {
+		tt.serverURL = newServer(&tt.sent, &mu)
+
+		options := []Option{WithBaseURL(tt.serverURL), WithRetryPolicy(nil)}
+		if tt.wantVersion != consts.ApiVersion {
+			options = append(options, WithAPIVersion(tt.wantVersion))
+		}
+		tt.client = NewClient(options...)
+	}
+
+	for _, tt := range cases {
+		if _, err := tt.client.Payment(newIdempotentPayment(t)); err != nil {
+			t.Fatalf("%s: Payment error = %v", tt.name, err)
+		}
+
+		base, err := url.Parse(tt.serverURL)
+		if err != nil {
+			t.Fatalf("%s: url.Parse error = %v", tt.name, err)
+		}
+
+		mu.Lock()
+		if len(tt.sent) != 2 {
+			t.Errorf("%s: server received %d requests, want createApp and createOrder", tt.name, len(tt.sent))
+		}
+		for _, request := range tt.sent {
+			if request.host != base.Host {
+				t.Errorf("%s: request host = %s, want %s", tt.name, request.host, base.Host)
+			}
+			if request.version != tt.wantVersion {
+				t.Errorf("%s: Api-Version = %q, want %q", tt.name, request.version, tt.wantVersion)
+			}
+		}
+		mu.Unlock()
+	}
+}
@@ -28,15 +28,27 @@ const (
 	Version    = "1.0.0"
 	ApiVersion = "1.28"
 
-	baseUrl = "https://merchantapi.easypay.ua"
+	// BaseURL is the production merchant API, clients can be pointed elsewhere with WithBaseURL
+	BaseURL = "https://merchantapi.easypay.ua"
 
-	CreateAppURL       = baseUrl + "/api/system/createApp"
-	CreatePageURL      = baseUrl + "/api/system/createPage"
-	CreateOrderURL     = baseUrl + "/api/merchant/createOrder"
-	CancelOrderURL     = baseUrl + "/api/merchant/CancelOrder"
-	CheckOrderStateURL = baseUrl + "/api/merchant/orderState"
-	CardTokenCreateURL = baseUrl + "/api/merchant/tokenCard/create"
-	UnHoldURL          = baseUrl + "/api/merchant/unHoldOrder"
+	CreateAppPath       = "/api/system/createApp"
+	CreatePagePath      = "/api/system/createPage"
+	CreateOrderPath     = "/api/merchant/createOrder"
+	CancelOrderPath     = "/api/merchant/CancelOrder"
+	CheckOrderStatePath = "/api/merchant/orderState"
+	CardTokenCreatePath = "/api/merchant/tokenCard/create"
+	UnHoldPath          = "/api/merchant/unHoldOrder"
+)
+
+// Production endpoints
+const (
+	CreateAppURL       = BaseURL + CreateAppPath
+	CreatePageURL      = BaseURL + CreatePagePath
+	CreateOrderURL     = BaseURL + CreateOrderPath
+	CancelOrderURL     = BaseURL + CancelOrderPath
+	CheckOrderStateURL = BaseURL + CheckOrderStatePath
+	CardTokenCreateURL = BaseURL + CardTokenCreatePath
+	UnHoldURL          = BaseURL + UnHoldPath
 )
 
 type PaymentOperation string
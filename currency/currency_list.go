@@ -24,6 +24,8 @@
 
 package currency
 
+import "strings"
+
 type Code string
 
 // Currency codes
@@ -33,6 +35,63 @@ const (
 	EUR Code = "EUR"
 )
 
+// Info holds the ISO 4217 metadata of a currency
+type Info struct {
+	Code       Code
+	Numeric    string
+	MinorUnits int
+	Name       string
+}
+
+var infos = map[Code]Info{
+	UAH: {Code: UAH, Numeric: "980", MinorUnits: 2, Name: "Ukrainian hryvnia"},
+	USD: {Code: USD, Numeric: "840", MinorUnits: 2, Name: "US dollar"},
+	EUR: {Code: EUR, Numeric: "978", MinorUnits: 2, Name: "Euro"},
+}
+
+// Lookup finds a supported currency by its alphabetic or numeric ISO 4217 code
+func Lookup(code string) (Info, bool) {
+	if info, ok := infos[Code(strings.ToUpper(code))]; ok {
+		return info, true
+	}
+
+	for _, info := range infos {
+		if info.Numeric == code {
+			return info, true
+		}
+	}
+
+	return Info{}, false
+}
+
 func (c Code) String() string {
 	return string(c)
 }
+
+// Info returns the ISO 4217 metadata of the currency
+func (c Code) Info() (Info, bool) {
+	info, ok := infos[c]
+
+	return info, ok
+}
+
+// IsValid reports whether the currency is known to the package
+func (c Code) IsValid() bool {
+	_, ok := infos[c]
+
+	return ok
+}
+
+// Numeric returns the ISO 4217 numeric code, empty for unknown currencies
+func (c Code) Numeric() string {
+	return infos[c].Numeric
+}
+
+// MinorUnits returns the number of digits after the decimal point, 2 for unknown currencies
+func (c Code) MinorUnits() int {
+	if info, ok := infos[c]; ok {
+		return info.MinorUnits
+	}
+
+	return defaultMinorUnits
+}
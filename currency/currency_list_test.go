@@ -0,0 +1,87 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package currency
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code   string
+		want   Code
+		wantOK bool
+	}{
+		{code: "UAH", want: UAH, wantOK: true},
+		{code: "usd", want: USD, wantOK: true},
+		{code: "978", want: EUR, wantOK: true},
+		{code: "980", want: UAH, wantOK: true},
+		{code: "GBP"},
+		{code: "826"},
+		{code: ""},
+	}
+
+	for _, tt := range tests {
+		info, ok := Lookup(tt.code)
+		if ok != tt.wantOK || info.Code != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.code, info.Code, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCodeInfo(t *testing.T) {
+	tests := []struct {
+		code           Code
+		wantOK         bool
+		wantNumeric    string
+		wantMinorUnits int
+		wantName       string
+	}{
+		{code: UAH, wantOK: true, wantNumeric: "980", wantMinorUnits: 2, wantName: "Ukrainian hryvnia"},
+		{code: USD, wantOK: true, wantNumeric: "840", wantMinorUnits: 2, wantName: "US dollar"},
+		{code: EUR, wantOK: true, wantNumeric: "978", wantMinorUnits: 2, wantName: "Euro"},
+		// unknown currencies have no numeric code and default to 2 minor units
+		{code: "GBP", wantMinorUnits: 2},
+		{code: "uah", wantMinorUnits: 2},
+		{code: "", wantMinorUnits: 2},
+	}
+
+	for _, tt := range tests {
+		info, ok := tt.code.Info()
+		if ok != tt.wantOK {
+			t.Errorf("%q.Info() ok = %v, want %v", tt.code, ok, tt.wantOK)
+		}
+		if ok && (info.Code != tt.code || info.Numeric != tt.wantNumeric || info.MinorUnits != tt.wantMinorUnits || info.Name != tt.wantName) {
+			t.Errorf("%q.Info() = %+v", tt.code, info)
+		}
+		if got := tt.code.IsValid(); got != tt.wantOK {
+			t.Errorf("%q.IsValid() = %v, want %v", tt.code, got, tt.wantOK)
+		}
+		if got := tt.code.Numeric(); got != tt.wantNumeric {
+			t.Errorf("%q.Numeric() = %q, want %q", tt.code, got, tt.wantNumeric)
+		}
+		if got := tt.code.MinorUnits(); got != tt.wantMinorUnits {
+			t.Errorf("%q.MinorUnits() = %d, want %d", tt.code, got, tt.wantMinorUnits)
+		}
+	}
+}
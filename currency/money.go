@@ -0,0 +1,328 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrCurrencyMismatch reports arithmetic on amounts in different currencies
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+// defaultMinorUnits is used for amounts without a currency, such as the ones decoded from API responses
+const defaultMinorUnits = 2
+
+// Money is an exact amount stored in minor units (kopiyky, cents) of its currency
+type Money struct {
+	minor int64
+	code  Code
+}
+
+// New creates Money from an amount in minor units, New(1999, UAH) is 19.99 UAH
+func New(minor int64, code Code) Money {
+	return Money{minor: minor, code: code}
+}
+
+// FromFloat creates Money from a major unit amount rounded to the nearest minor unit
+func FromFloat(amount float64, code Code) Money {
+	return Money{minor: int64(math.Round(amount * math.Pow10(code.MinorUnits()))), code: code}
+}
+
+// Parse creates Money from a decimal string such as "19.99" without going through float64
+func Parse(amount string, code Code) (Money, error) {
+	minor, err := parseMinor(amount, code.MinorUnits())
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: minor, code: code}, nil
+}
+
+// MustParse is like Parse but panics when the amount is malformed
+func MustParse(amount string, code Code) Money {
+	m, err := Parse(amount, code)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// MinorUnits returns the amount in minor units
+func (m Money) MinorUnits() int64 {
+	return m.minor
+}
+
+// Currency returns the currency code, empty when the amount was decoded from an API response
+func (m Money) Currency() Code {
+	return m.code
+}
+
+// WithCurrency returns the same amount in the given currency
+func (m Money) WithCurrency(code Code) Money {
+	return Money{minor: m.minor, code: code}
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+// SameCurrency reports whether the amounts can be added or compared, an amount without a currency
+// is in the currency of the other one
+func (m Money) SameCurrency(other Money) bool {
+	return m.code == "" || other.code == "" || m.code == other.code
+}
+
+// CheckCurrency returns ErrCurrencyMismatch when the amounts are in different currencies
+func (m Money) CheckCurrency(other Money) error {
+	if !m.SameCurrency(other) {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.code, other.code)
+	}
+
+	return nil
+}
+
+// MustSameCurrency panics with ErrCurrencyMismatch when the amounts are in different currencies
+func (m Money) MustSameCurrency(other Money) {
+	if err := m.CheckCurrency(other); err != nil {
+		panic(err)
+	}
+}
+
+// AddChecked returns the sum of both amounts, ErrCurrencyMismatch when they are in different currencies
+func (m Money) AddChecked(other Money) (Money, error) {
+	if err := m.CheckCurrency(other); err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: m.minor + other.minor, code: m.mergeCode(other)}, nil
+}
+
+// SubChecked returns the difference of both amounts, ErrCurrencyMismatch when they are in different currencies
+func (m Money) SubChecked(other Money) (Money, error) {
+	if err := m.CheckCurrency(other); err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: m.minor - other.minor, code: m.mergeCode(other)}, nil
+}
+
+// Compare returns -1, 0 or +1 when m is less than, equal to or greater than other,
+// ErrCurrencyMismatch when they are in different currencies
+func (m Money) Compare(other Money) (int, error) {
+	if err := m.CheckCurrency(other); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.minor < other.minor:
+		return -1, nil
+	case m.minor > other.minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Add is like AddChecked but panics when the amounts are in different currencies,
+// use it only for amounts known to be in the same currency
+func (m Money) Add(other Money) Money {
+	m.MustSameCurrency(other)
+
+	sum, _ := m.AddChecked(other)
+
+	return sum
+}
+
+// Sub is like SubChecked but panics when the amounts are in different currencies,
+// use it only for amounts known to be in the same currency
+func (m Money) Sub(other Money) Money {
+	m.MustSameCurrency(other)
+
+	difference, _ := m.SubChecked(other)
+
+	return difference
+}
+
+// Cmp is like Compare but panics when the amounts are in different currencies,
+// use it only for amounts known to be in the same currency
+func (m Money) Cmp(other Money) int {
+	m.MustSameCurrency(other)
+
+	cmp, _ := m.Compare(other)
+
+	return cmp
+}
+
+// Float64 returns the amount in major units, use it for display only
+func (m Money) Float64() float64 {
+	return float64(m.minor) / math.Pow10(m.code.MinorUnits())
+}
+
+// Decimal returns the amount in major units with all minor digits, e.g. "19.99"
+func (m Money) Decimal() string {
+	exponent := m.code.MinorUnits()
+
+	sign := ""
+	minor := m.minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	digits := strconv.FormatInt(minor, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	if m.code == "" {
+		return m.Decimal()
+	}
+
+	return m.Decimal() + " " + m.code.String()
+}
+
+// MarshalJSON encodes the amount as the decimal number Easypay expects
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string without losing precision
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+
+	if raw == "" {
+		m.minor = 0
+		return nil
+	}
+
+	minor, err := parseMinor(raw, m.code.MinorUnits())
+	if err != nil {
+		return err
+	}
+
+	m.minor = minor
+
+	return nil
+}
+
+func (m Money) mergeCode(other Money) Code {
+	if m.code != "" {
+		return m.code
+	}
+
+	return other.code
+}
+
+// parseMinor converts a decimal string to minor units, extra digits are rounded half away from zero
+func parseMinor(amount string, exponent int) (int64, error) {
+	s := strings.TrimSpace(amount)
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", amount)
+		}
+
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	if whole == "" {
+		whole = "0"
+	}
+
+	roundUp := false
+	if len(fraction) > exponent {
+		roundUp = fraction[exponent] >= '5'
+		fraction = fraction[:exponent]
+	}
+
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+
+	if roundUp {
+		minor++
+	}
+
+	if negative {
+		minor = -minor
+	}
+
+	return minor, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,258 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMinor(t *testing.T) {
+	tests := []struct {
+		amount   string
+		exponent int
+		want     int64
+		wantErr  bool
+	}{
+		{amount: "19.99", exponent: 2, want: 1999},
+		{amount: "0.995", exponent: 2, want: 100},
+		{amount: "0.994", exponent: 2, want: 99},
+		{amount: "-0.005", exponent: 2, want: -1},
+		{amount: "-0.004", exponent: 2, want: 0},
+		{amount: "-19.99", exponent: 2, want: -1999},
+		{amount: "+5", exponent: 2, want: 500},
+		{amount: "12.3", exponent: 2, want: 1230},
+		{amount: ".5", exponent: 2, want: 50},
+		{amount: "7.", exponent: 2, want: 700},
+		{amount: " 1.5 ", exponent: 2, want: 150},
+		{amount: "1e-7", exponent: 2, want: 0},
+		{amount: "1.5e2", exponent: 2, want: 15000},
+		{amount: "2E-2", exponent: 2, want: 2},
+		{amount: "19.5", exponent: 0, want: 20},
+		{amount: "1.2345", exponent: 3, want: 1235},
+		{amount: "", exponent: 2, wantErr: true},
+		{amount: "-", exponent: 2, wantErr: true},
+		{amount: ".", exponent: 2, wantErr: true},
+		{amount: "1.2.3", exponent: 2, wantErr: true},
+		{amount: "--1", exponent: 2, wantErr: true},
+		{amount: "abc", exponent: 2, wantErr: true},
+		{amount: "1e", exponent: 2, wantErr: true},
+		{amount: "99999999999999999999", exponent: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMinor(tt.amount, tt.exponent)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMinor(%q, %d) = %d, want an error", tt.amount, tt.exponent, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseMinor(%q, %d) error = %v", tt.amount, tt.exponent, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseMinor(%q, %d) = %d, want %d", tt.amount, tt.exponent, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: New(1999, UAH), want: "19.99"},
+		{money: New(5, UAH), want: "0.05"},
+		{money: New(0, UAH), want: "0.00"},
+		{money: New(-1, UAH), want: "-0.01"},
+		{money: New(-1999, USD), want: "-19.99"},
+		{money: New(100, ""), want: "1.00"},
+		{money: MustParse("0.995", EUR), want: "1.00"},
+		{money: FromFloat(19.99, UAH), want: "19.99"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("Decimal() of %d minor units = %q, want %q", tt.money.MinorUnits(), got, tt.want)
+		}
+
+		raw, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Errorf("Marshal(%s) error = %v", tt.want, err)
+			continue
+		}
+
+		if string(raw) != tt.want {
+			t.Errorf("Marshal() = %s, want %s", raw, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Money
+		sum      Money
+		diff     Money
+		cmp      int
+		mismatch bool
+	}{
+		{name: "same currency", a: New(1000, UAH), b: New(250, UAH), sum: New(1250, UAH), diff: New(750, UAH), cmp: 1},
+		{name: "less", a: New(100, USD), b: New(250, USD), sum: New(350, USD), diff: New(-150, USD), cmp: -1},
+		{name: "equal", a: New(100, EUR), b: New(100, EUR), sum: New(200, EUR), diff: New(0, EUR), cmp: 0},
+		{name: "decoded amount takes the currency", a: New(1000, ""), b: New(250, UAH), sum: New(1250, UAH), diff: New(750, UAH), cmp: 1},
+		{name: "decoded amount keeps the currency", a: New(1000, UAH), b: New(1000, ""), sum: New(2000, UAH), diff: New(0, UAH), cmp: 0},
+		{name: "both decoded", a: New(1, ""), b: New(2, ""), sum: New(3, ""), diff: New(-1, ""), cmp: -1},
+		{name: "different currencies", a: New(1000, UAH), b: New(1000, USD), mismatch: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.SameCurrency(tt.b); got == tt.mismatch {
+			t.Errorf("%s: SameCurrency() = %v, want %v", tt.name, got, !tt.mismatch)
+		}
+
+		if tt.mismatch {
+			_, addErr := tt.a.AddChecked(tt.b)
+			_, subErr := tt.a.SubChecked(tt.b)
+			_, cmpErr := tt.a.Compare(tt.b)
+			for op, err := range map[string]error{"CheckCurrency": tt.a.CheckCurrency(tt.b), "AddChecked": addErr, "SubChecked": subErr, "Compare": cmpErr} {
+				if !errors.Is(err, ErrCurrencyMismatch) {
+					t.Errorf("%s: %s() error = %v, want %v", tt.name, op, err, ErrCurrencyMismatch)
+				}
+			}
+
+			for op, f := range map[string]func(){
+				"Add": func() { tt.a.Add(tt.b) },
+				"Sub": func() { tt.a.Sub(tt.b) },
+				"Cmp": func() { tt.a.Cmp(tt.b) },
+			} {
+				if err := recoverError(f); !errors.Is(err, ErrCurrencyMismatch) {
+					t.Errorf("%s: %s() panicked with %v, want %v", tt.name, op, err, ErrCurrencyMismatch)
+				}
+			}
+			continue
+		}
+
+		if got := tt.a.Add(tt.b); got != tt.sum {
+			t.Errorf("%s: Add() = %v, want %v", tt.name, got, tt.sum)
+		}
+
+		if got := tt.a.Sub(tt.b); got != tt.diff {
+			t.Errorf("%s: Sub() = %v, want %v", tt.name, got, tt.diff)
+		}
+
+		if got := tt.a.Cmp(tt.b); got != tt.cmp {
+			t.Errorf("%s: Cmp() = %d, want %d", tt.name, got, tt.cmp)
+		}
+
+		if got, err := tt.a.AddChecked(tt.b); err != nil || got != tt.sum {
+			t.Errorf("%s: AddChecked() = %v, %v, want %v", tt.name, got, err, tt.sum)
+		}
+
+		if got, err := tt.a.SubChecked(tt.b); err != nil || got != tt.diff {
+			t.Errorf("%s: SubChecked() = %v, %v, want %v", tt.name, got, err, tt.diff)
+		}
+
+		if got, err := tt.a.Compare(tt.b); err != nil || got != tt.cmp {
+			t.Errorf("%s: Compare() = %d, %v, want %d", tt.name, got, err, tt.cmp)
+		}
+	}
+}
+
+// recoverError runs f and returns the error it panicked with
+func recoverError(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, _ = r.(error)
+		}
+	}()
+
+	f()
+
+	return nil
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    int64
+		wantErr bool
+	}{
+		{json: `19.99`, want: 1999},
+		{json: `"19.99"`, want: 1999},
+		{json: `"12.3"`, want: 1230},
+		{json: `0.995`, want: 100},
+		{json: `-0.005`, want: -1},
+		{json: `1e-7`, want: 0},
+		{json: `""`, want: 0},
+		{json: `null`, want: 42},
+		{json: `"abc"`, wantErr: true},
+		{json: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		m := New(42, UAH)
+
+		err := json.Unmarshal([]byte(tt.json), &m)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %d, want an error", tt.json, m.MinorUnits())
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.json, err)
+			continue
+		}
+
+		if m.MinorUnits() != tt.want {
+			t.Errorf("Unmarshal(%s) = %d minor units, want %d", tt.json, m.MinorUnits(), tt.want)
+		}
+
+		if m.Currency() != UAH {
+			t.Errorf("Unmarshal(%s) changed the currency to %q", tt.json, m.Currency())
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSONField(t *testing.T) {
+	var body struct {
+		Amount *Money `json:"amount"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": null}`), &body); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+
+	if body.Amount != nil {
+		t.Errorf("null amount = %s, want nil", body.Amount)
+	}
+}
@@ -1,6 +1,9 @@
 package easypay
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type App struct {
 	logoURL    *string
@@ -35,3 +38,8 @@ func (a *App) APIVersion() string {
 func (a *App) AppID() string {
 	return *a.appID
 }
+
+// IsAppRejected reports whether err is an API error caused by a rejected AppId
+func IsAppRejected(err error) bool {
+	return errors.Is(err, ErrAppExpired)
+}
@@ -1,12 +1,59 @@
 package easypay
 
+import "github.com/stremovskyy/go-easypay/currency"
+
 // CancelPaymentResponse is the structure for the response from cancelling a payment
 type CancelPaymentResponse struct {
-	MerchantKey         string  `json:"merchantKey"`
-	TransactionID       int64   `json:"transactionId"`
-	RefundTransactionID int64   `json:"refundTransactionId"`
-	OrderID             string  `json:"orderId"`
-	Amount              float64 `json:"amount"`
-	PaymentState        string  `json:"paymentState"`
-	Error               *Error  `json:"error"`
+	MerchantKey         string         `json:"merchantKey"`
+	TransactionID       int64          `json:"transactionId"`
+	RefundTransactionID int64          `json:"refundTransactionId"`
+	OrderID             string         `json:"orderId"`
+	Amount              currency.Money `json:"amount"`
+	PaymentState        Status         `json:"paymentState"`
+	Error               *Error         `json:"error"`
+	// Ledger holds the refunds of the order including this one
+	Ledger *RefundLedger `json:"-"`
+}
+
+// NewCancelPaymentResponse takes the cancellation fields of a generic response
+func NewCancelPaymentResponse(r *Response) *CancelPaymentResponse {
+	response := &CancelPaymentResponse{
+		PaymentState: r.PaymentState,
+		Error:        r.Error,
+	}
+
+	if r.MerchantKey != nil {
+		response.MerchantKey = *r.MerchantKey
+	}
+	if r.TransactionId != nil {
+		response.TransactionID = *r.TransactionId
+	}
+	if r.RefundTransactionId != nil {
+		response.RefundTransactionID = int64(*r.RefundTransactionId)
+	}
+	if r.OrderId != nil {
+		response.OrderID = *r.OrderId
+	}
+	if r.Amount != nil {
+		response.Amount = *r.Amount
+	}
+
+	return response
+}
+
+func (r *CancelPaymentResponse) GetError() error {
+	if r.Error != nil {
+		return &CustomError{Resp: &Response{Error: r.Error}}
+	}
+
+	return nil
+}
+
+func (r *CancelPaymentResponse) Reset() {
+	*r = CancelPaymentResponse{}
+}
+
+// IsAccepted reports whether Easypay accepted the cancellation
+func (r *CancelPaymentResponse) IsAccepted() bool {
+	return r.PaymentState == StatusCancelingAccepted
 }
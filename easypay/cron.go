@@ -0,0 +1,116 @@
+package easypay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCronRule is matched by every error returned by CronRule.Validate
+var ErrInvalidCronRule = errors.New("invalid cron rule")
+
+// CronRule is a five field cron expression (minute hour day-of-month month day-of-week) used by recurrent payments
+type CronRule string
+
+// cronFieldRanges holds the allowed bounds of every cron rule field
+var cronFieldRanges = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Daily runs every day at the given time
+func Daily(hour, minute int) CronRule {
+	return CronRule(fmt.Sprintf("%d %d * * *", minute, hour))
+}
+
+// Weekly runs every week on the given weekday at the given time
+func Weekly(day time.Weekday, hour, minute int) CronRule {
+	return CronRule(fmt.Sprintf("%d %d * * %d", minute, hour, day))
+}
+
+// Monthly runs every month on the given day at the given time
+func Monthly(day, hour, minute int) CronRule {
+	return CronRule(fmt.Sprintf("%d %d %d * *", minute, hour, day))
+}
+
+// EveryNDays runs every n days at the given time
+func EveryNDays(n, hour, minute int) CronRule {
+	return CronRule(fmt.Sprintf("%d %d */%d * *", minute, hour, n))
+}
+
+func (r CronRule) String() string {
+	return string(r)
+}
+
+// Validate checks that the rule has five fields and every value is within its range
+func (r CronRule) Validate() error {
+	fields := strings.Fields(string(r))
+	if len(fields) != len(cronFieldRanges) {
+		return fmt.Errorf("%w %q: must have %d fields, got %d", ErrInvalidCronRule, r, len(cronFieldRanges), len(fields))
+	}
+
+	for i, field := range fields {
+		bounds := cronFieldRanges[i]
+		if err := validateCronField(field, bounds.min, bounds.max); err != nil {
+			return fmt.Errorf("%w %q: invalid %s: %v", ErrInvalidCronRule, r, bounds.name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCronField(field string, min, max int) error {
+	for _, item := range strings.Split(field, ",") {
+		value, step, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			n, err := strconv.Atoi(step)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("bad step %q", step)
+			}
+		}
+
+		if value == "*" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(value, "-")
+		start, err := parseCronValue(from, min, max)
+		if err != nil {
+			return err
+		}
+
+		if isRange {
+			end, err := parseCronValue(to, min, max)
+			if err != nil {
+				return err
+			}
+
+			if start > end {
+				return fmt.Errorf("bad range %q", value)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseCronValue(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", value)
+	}
+
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, min, max)
+	}
+
+	return n, nil
+}
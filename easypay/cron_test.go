@@ -0,0 +1,76 @@
+package easypay
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCronRuleHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		rule CronRule
+		want string
+	}{
+		{name: "daily", rule: Daily(9, 30), want: "30 9 * * *"},
+		{name: "daily at midnight", rule: Daily(0, 0), want: "0 0 * * *"},
+		{name: "weekly", rule: Weekly(time.Monday, 10, 0), want: "0 10 * * 1"},
+		{name: "weekly on sunday", rule: Weekly(time.Sunday, 23, 59), want: "59 23 * * 0"},
+		{name: "monthly", rule: Monthly(15, 12, 5), want: "5 12 15 * *"},
+		{name: "every n days", rule: EveryNDays(3, 8, 45), want: "45 8 */3 * *"},
+	}
+
+	for _, tt := range tests {
+		if tt.rule.String() != tt.want {
+			t.Errorf("%s: rule = %q, want %q", tt.name, tt.rule, tt.want)
+		}
+
+		if err := tt.rule.Validate(); err != nil {
+			t.Errorf("%s: Validate(%q) error = %v", tt.name, tt.rule, err)
+		}
+	}
+}
+
+func TestCronRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule    CronRule
+		wantErr bool
+	}{
+		{rule: "0 12 * * *"},
+		{rule: "*/15 * * * *"},
+		{rule: "0 9-18 * * 1-5"},
+		{rule: "0,30 8,20 1,15 * *"},
+		{rule: "0 0 1 1,6,12 7"},
+		{rule: "0 0 */2 * *"},
+		{rule: "1-59/2 * * * *"},
+		{rule: "", wantErr: true},
+		{rule: "0 12 * *", wantErr: true},
+		{rule: "0 12 * * * *", wantErr: true},
+		{rule: "60 12 * * *", wantErr: true},
+		{rule: "0 24 * * *", wantErr: true},
+		{rule: "0 12 0 * *", wantErr: true},
+		{rule: "0 12 32 * *", wantErr: true},
+		{rule: "0 12 * 13 *", wantErr: true},
+		{rule: "0 12 * * 8", wantErr: true},
+		{rule: "0 18-9 * * *", wantErr: true},
+		{rule: "*/0 * * * *", wantErr: true},
+		{rule: "*/x * * * *", wantErr: true},
+		{rule: "a 12 * * *", wantErr: true},
+		{rule: "-1 12 * * *", wantErr: true},
+		{rule: EveryNDays(0, 8, 0), wantErr: true},
+		{rule: Daily(25, 0), wantErr: true},
+		{rule: Monthly(31, 0, 60), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.rule.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+			continue
+		}
+
+		if err != nil && !errors.Is(err, ErrInvalidCronRule) {
+			t.Errorf("Validate(%q) error = %v, want ErrInvalidCronRule", tt.rule, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package easypay
+
+import (
+	"errors"
+	"strings"
+)
+
+// Known API errors, CustomError matches them with errors.Is by its error code or client error code
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCardExpired       = errors.New("card expired")
+	ErrCardDeclined      = errors.New("card declined")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrDuplicateOrder    = errors.New("duplicate order")
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrAppExpired        = errors.New("app expired")
+	ErrTemporary         = errors.New("temporary error")
+)
+
+// Error codes of the known API errors. Easypay publishes no error code catalogue that could be linked here,
+// so the list is not exhaustive: it holds the codes the client relies on to renew Apps, resolve operations
+// and retry requests. Other codes are mapped per client with ErrorCodes.
+const (
+	CodeInsufficientFunds   = "INSUFFICIENT_FUNDS"
+	CodeCardExpired         = "CARD_EXPIRED"
+	CodeCardDeclined        = "CARD_DECLINED"
+	CodeInvalidSignature    = "INVALID_SIGNATURE"
+	CodeInvalidSign         = "INVALID_SIGN"
+	CodeDuplicateOrder      = "DUPLICATE_ORDER"
+	CodeOrderNotFound       = "ORDER_NOT_FOUND"
+	CodeTransactionNotFound = "TRANSACTION_NOT_FOUND"
+	CodeInvalidAmount       = "INVALID_AMOUNT"
+	CodeAppExpired          = "APP_EXPIRED"
+	CodeAppNotFound         = "APP_NOT_FOUND"
+	CodeInternalError       = "INTERNAL_ERROR"
+)
+
+// errorCodes maps normalised Easypay error codes to the known errors
+var errorCodes = map[string]error{
+	CodeInsufficientFunds:   ErrInsufficientFunds,
+	CodeCardExpired:         ErrCardExpired,
+	CodeCardDeclined:        ErrCardDeclined,
+	CodeInvalidSignature:    ErrInvalidSignature,
+	CodeInvalidSign:         ErrInvalidSignature,
+	CodeDuplicateOrder:      ErrDuplicateOrder,
+	CodeOrderNotFound:       ErrOrderNotFound,
+	CodeTransactionNotFound: ErrOrderNotFound,
+	CodeInvalidAmount:       ErrInvalidAmount,
+	CodeAppExpired:          ErrAppExpired,
+	CodeAppNotFound:         ErrAppExpired,
+	CodeInternalError:       ErrTemporary,
+}
+
+// ErrorCodes maps the Easypay error codes of one client to the known errors, on top of the built-in codes.
+// Retries and the renewal of rejected Apps follow the mapping, ErrTemporary codes are retried and ErrAppExpired
+// codes create a new App. A code mapped to nil is not known, even if it is built in.
+type ErrorCodes map[string]error
+
+// NewErrorCodes normalises the codes of the mapping, so they match regardless of case and separators
+func NewErrorCodes(codes map[string]error) ErrorCodes {
+	normalised := make(ErrorCodes, len(codes))
+	for code, known := range codes {
+		normalised[normaliseCode(code)] = known
+	}
+
+	return normalised
+}
+
+// Known returns the known error for the Easypay error code, nil for unknown codes
+func (c ErrorCodes) Known(code string) error {
+	if known, ok := c[normaliseCode(code)]; ok {
+		return known
+	}
+
+	return KnownError(code)
+}
+
+// Apply makes the API error returned by GetError match the known errors of the mapping, other errors are kept
+func (c ErrorCodes) Apply(err error) error {
+	customError, ok := err.(*CustomError)
+	if !ok || len(c) == 0 {
+		return err
+	}
+
+	return &CustomError{Resp: customError.Resp, codes: c}
+}
+
+// FieldError describes a rejected request field
+type FieldError struct {
+	FieldName    string      `json:"fieldName"`
+	ErrorCode    interface{} `json:"errorCode"`
+	ErrorMessage string      `json:"errorMessage"`
+}
+
+// KnownError returns the known error for the Easypay error code, nil for unknown codes
+func KnownError(code string) error {
+	return errorCodes[normaliseCode(code)]
+}
+
+// normaliseCode matches codes regardless of case and of the separator between words
+func normaliseCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", " ", "_", ".", "_").Replace(strings.TrimSpace(code)))
+}
+
+// AsAPIError returns the API error wrapped in err
+func AsAPIError(err error) (*CustomError, bool) {
+	var customError *CustomError
+	if !errors.As(err, &customError) || customError.Resp == nil || customError.Resp.Error == nil {
+		return nil, false
+	}
+
+	return customError, true
+}
+
+// FieldErrors returns the rejected fields of the API error wrapped in err
+func FieldErrors(err error) []FieldError {
+	customError, ok := AsAPIError(err)
+	if !ok {
+		return nil
+	}
+
+	return customError.FieldErrors()
+}
+
+// Code returns the Easypay error code
+func (ce *CustomError) Code() string {
+	if ce.Resp == nil || ce.Resp.Error == nil || ce.Resp.Error.ErrorCode == nil {
+		return ""
+	}
+
+	return *ce.Resp.Error.ErrorCode
+}
+
+// ClientCode returns the Easypay client error code
+func (ce *CustomError) ClientCode() string {
+	if ce.Resp == nil || ce.Resp.Error == nil || ce.Resp.Error.ClientErrorCode == nil {
+		return ""
+	}
+
+	return *ce.Resp.Error.ClientErrorCode
+}
+
+// FieldErrors returns the rejected request fields
+func (ce *CustomError) FieldErrors() []FieldError {
+	if ce.Resp == nil || ce.Resp.Error == nil {
+		return nil
+	}
+
+	return ce.Resp.Error.FieldErrors
+}
+
+// Unwrap returns the known error of the error code, so errors.Is(err, ErrInsufficientFunds) works
+func (ce *CustomError) Unwrap() error {
+	if known := ce.codes.Known(ce.Code()); known != nil {
+		return known
+	}
+
+	return ce.codes.Known(ce.ClientCode())
+}
@@ -0,0 +1,196 @@
+package easypay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+func apiError(code string, clientCode string, fields ...FieldError) *CustomError {
+	e := &Error{FieldErrors: fields}
+	if code != "" {
+		e.ErrorCode = utils.Ref(code)
+	}
+	if clientCode != "" {
+		e.ClientErrorCode = utils.Ref(clientCode)
+	}
+
+	return &CustomError{Resp: &Response{Error: e}}
+}
+
+func TestKnownError(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{code: CodeInsufficientFunds, want: ErrInsufficientFunds},
+		{code: CodeCardExpired, want: ErrCardExpired},
+		{code: CodeCardDeclined, want: ErrCardDeclined},
+		{code: CodeInvalidSignature, want: ErrInvalidSignature},
+		{code: CodeInvalidSign, want: ErrInvalidSignature},
+		{code: CodeDuplicateOrder, want: ErrDuplicateOrder},
+		{code: CodeOrderNotFound, want: ErrOrderNotFound},
+		{code: CodeTransactionNotFound, want: ErrOrderNotFound},
+		{code: CodeInvalidAmount, want: ErrInvalidAmount},
+		{code: CodeAppExpired, want: ErrAppExpired},
+		{code: CodeAppNotFound, want: ErrAppExpired},
+		{code: CodeInternalError, want: ErrTemporary},
+		{code: "app-expired", want: ErrAppExpired},
+		{code: " App Expired ", want: ErrAppExpired},
+		{code: "order.not.found", want: ErrOrderNotFound},
+		{code: "SOMETHING_ELSE", want: nil},
+		{code: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := KnownError(tt.code); got != tt.want {
+			t.Errorf("KnownError(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	codes := NewErrorCodes(map[string]error{"do-not-honor": ErrCardDeclined, CodeInternalError: nil})
+
+	tests := []struct {
+		code string
+		want error
+	}{
+		{code: "DO_NOT_HONOR", want: ErrCardDeclined},
+		{code: "do not honor", want: ErrCardDeclined},
+		{code: CodeAppNotFound, want: ErrAppExpired},
+		{code: CodeInternalError, want: nil},
+		{code: "SOMETHING_ELSE", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := codes.Known(tt.code); got != tt.want {
+			t.Errorf("Known(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	err := fmt.Errorf("easypay error: %w", codes.Apply(apiError("DO_NOT_HONOR", "")))
+	if !errors.Is(err, ErrCardDeclined) {
+		t.Errorf("errors.Is(%v, ErrCardDeclined) = false with the code mapped", err)
+	}
+
+	// the mapping belongs to the errors it was applied to, other errors keep the built-in codes
+	if errors.Is(apiError("DO_NOT_HONOR", ""), ErrCardDeclined) {
+		t.Error("errors.Is(DO_NOT_HONOR, ErrCardDeclined) = true without the mapping")
+	}
+	if !errors.Is(apiError(CodeInternalError, ""), ErrTemporary) {
+		t.Error("errors.Is(INTERNAL_ERROR, ErrTemporary) = false without the mapping")
+	}
+
+	if other := errors.New("other"); codes.Apply(other) != other {
+		t.Error("Apply changed an error that is not an API error")
+	}
+}
+
+func TestCustomErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *CustomError
+		want error
+	}{
+		{name: "error code", err: apiError(CodeCardDeclined, ""), want: ErrCardDeclined},
+		{name: "client error code", err: apiError("", CodeInsufficientFunds), want: ErrInsufficientFunds},
+		{name: "error code before client error code", err: apiError(CodeAppExpired, CodeCardDeclined), want: ErrAppExpired},
+		{name: "unknown error code with known client code", err: apiError("UNKNOWN", CodeOrderNotFound), want: ErrOrderNotFound},
+		{name: "unknown codes", err: apiError("UNKNOWN", "ALSO_UNKNOWN"), want: nil},
+		{name: "no codes", err: apiError("", ""), want: nil},
+		{name: "no error", err: &CustomError{Resp: &Response{}}, want: nil},
+		{name: "no response", err: &CustomError{}, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Unwrap(); got != tt.want {
+			t.Errorf("%s: Unwrap() = %v, want %v", tt.name, got, tt.want)
+		}
+
+		// the known error is found through every layer the client wraps API errors in
+		httpError := NewHTTPError(http.StatusBadRequest, http.Header{}, nil, "request-1")
+		httpError.Err = tt.err
+		wrapped := fmt.Errorf("error while creating payment: %w", fmt.Errorf("unexpected response status: %w", httpError))
+
+		if tt.want != nil && !errors.Is(wrapped, tt.want) {
+			t.Errorf("%s: errors.Is(%v, %v) = false", tt.name, wrapped, tt.want)
+		}
+		if tt.want != ErrTemporary && errors.Is(wrapped, ErrTemporary) {
+			t.Errorf("%s: errors.Is(%v, ErrTemporary) = true", tt.name, wrapped)
+		}
+	}
+}
+
+func TestAsAPIError(t *testing.T) {
+	declined := apiError(CodeCardDeclined, "")
+
+	httpError := NewHTTPError(http.StatusBadRequest, http.Header{}, nil, "request-1")
+	httpError.Err = declined
+
+	tests := []struct {
+		name string
+		err  error
+		want *CustomError
+	}{
+		{name: "api error", err: declined, want: declined},
+		{name: "wrapped api error", err: fmt.Errorf("easypay error: %w", declined), want: declined},
+		{name: "api error in http error", err: fmt.Errorf("unexpected response status: %w", httpError), want: declined},
+		{name: "http error without api error", err: NewHTTPError(http.StatusBadGateway, http.Header{}, []byte("bad gateway"), "request-1"), want: nil},
+		{name: "response without error", err: &CustomError{Resp: &Response{}}, want: nil},
+		{name: "error without response", err: &CustomError{}, want: nil},
+		{name: "other error", err: errors.New("connection refused"), want: nil},
+		{name: "nil", err: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := AsAPIError(tt.err)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("%s: AsAPIError(%v) = %v, %v, want %v", tt.name, tt.err, got, ok, tt.want)
+		}
+	}
+
+	// errors.As finds the API error as well
+	var customError *CustomError
+	if !errors.As(fmt.Errorf("unexpected response status: %w", httpError), &customError) || customError != declined {
+		t.Errorf("errors.As found %v, want the API error", customError)
+	}
+	if customError.Code() != CodeCardDeclined || customError.ClientCode() != "" {
+		t.Errorf("Code() = %q, ClientCode() = %q", customError.Code(), customError.ClientCode())
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	fields := []FieldError{
+		{FieldName: "order.amount", ErrorCode: "INVALID_AMOUNT", ErrorMessage: "amount must be positive"},
+		{FieldName: "order.orderId", ErrorCode: float64(12), ErrorMessage: "order ID is required"},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want []FieldError
+	}{
+		{name: "api error", err: apiError("VALIDATION_ERROR", "", fields...), want: fields},
+		{name: "wrapped api error", err: fmt.Errorf("error while creating payment: %w", apiError("VALIDATION_ERROR", "", fields...)), want: fields},
+		{name: "api error without fields", err: apiError(CodeCardDeclined, ""), want: nil},
+		{name: "other error", err: errors.New("connection refused"), want: nil},
+	}
+
+	for _, tt := range tests {
+		got := FieldErrors(tt.err)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: FieldErrors() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: FieldErrors()[%d] = %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
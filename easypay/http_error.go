@@ -0,0 +1,80 @@
+package easypay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxErrorBodySize limits the part of the response body kept in HTTPError
+const maxErrorBodySize = 1024
+
+// HTTPError is returned when Easypay or a proxy in front of it answers with a non-2xx status code
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	// Body is the beginning of the response body, truncated to maxErrorBodySize bytes
+	Body string
+	// RequestID is the X-Request-ID of the response, or of the request when the response has none
+	RequestID string
+	// Err is the API error described by the body, nil when the body is not an Easypay response
+	Err error
+}
+
+func NewHTTPError(statusCode int, header http.Header, body []byte, requestID string) *HTTPError {
+	if responseID := header.Get("X-Request-ID"); responseID != "" {
+		requestID = responseID
+	}
+
+	if len(body) > maxErrorBodySize {
+		body = body[:maxErrorBodySize]
+	}
+
+	return &HTTPError{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       string(body),
+		RequestID:  requestID,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("http status %d (request %s): %v", e.StatusCode, e.RequestID, e.Err)
+	}
+
+	return fmt.Sprintf("http status %d (request %s): %s", e.StatusCode, e.RequestID, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// IsAPIError reports whether the body carried an Easypay rejection, otherwise the failure came from the infrastructure
+func (e *HTTPError) IsAPIError() bool {
+	return e.Err != nil
+}
+
+// Temporary reports whether the request may succeed when sent again
+func (e *HTTPError) Temporary() bool {
+	if e.Err != nil {
+		return errors.Is(e.Err, ErrTemporary)
+	}
+
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// AsHTTPError returns the HTTPError wrapped in err
+func AsHTTPError(err error) (*HTTPError, bool) {
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) {
+		return nil, false
+	}
+
+	return httpError, true
+}
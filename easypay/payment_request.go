@@ -1,5 +1,11 @@
 package easypay
 
+import (
+	"encoding/json"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
 // Request represents the request body for creating an order
 type Request struct {
 	UserInfo              *UserInfo              `json:"userInfo,omitempty"`
@@ -15,15 +21,26 @@ type Request struct {
 	ServiceKey            *string                `json:"serviceKey,omitempty"`
 	OrderID               *string                `json:"orderId,omitempty"`
 	TransactionID         *int64                 `json:"transactionId,omitempty"`
-	Amount                *float64               `json:"amount,omitempty"` // optional for full cancellation
+	Amount                *currency.Money        `json:"amount,omitempty"` // optional for full cancellation
 	Phone                 *string                `json:"phone,omitempty"`
 
 	Url                 string            `json:"-"`
 	Headers             map[string]string `json:"-"`
 	SecretKey           string            `json:"-"`
 	SkipGeneratingError bool              `json:"-"`
+	// Operation names the client call the request is sent for, retries can be enabled per operation
+	Operation Operation `json:"-"`
+	// Retryable marks requests that are safe to send again after a transient failure
+	Retryable bool `json:"-"`
+	// IdempotencyKey is the order ID of a createOrder request, it lets the request be retried because Easypay
+	// rejects a second order with the same ID as DUPLICATE_ORDER. It is not sent, requests without a duplicate
+	// check of their own leave it empty and are never resent.
+	IdempotencyKey string `json:"-"`
 }
 
+// Operation names a client call, such as a payment or a status check
+type Operation string
+
 // UserInfo holds user-specific information
 type UserInfo struct {
 	Phone *string `json:"phone"`
@@ -34,7 +51,8 @@ type Order struct {
 	ServiceKey       *string            `json:"serviceKey,omitempty"`
 	OrderID          *string            `json:"orderId,omitempty"`
 	Description      *string            `json:"description,omitempty"`
-	Amount           *float64           `json:"amount,omitempty"`
+	Amount           *currency.Money    `json:"amount,omitempty"`
+	Currency         *string            `json:"currency,omitempty"`
 	PaymentOperation *string            `json:"paymentOperation,omitempty"`
 	AdditionalItems  *map[string]string `json:"additionalItems,omitempty"`
 	Expire           *string            `json:"expire,omitempty"`
@@ -87,6 +105,9 @@ type Bank struct {
 	Account string `json:"account,omitempty"`
 }
 
+// RecurrentDateLayout is the date format of the recurrent dateRun and dateExpire fields
+const RecurrentDateLayout = "2006-01-02T15:04:05"
+
 // Recurrent details for recurring payments
 type Recurrent struct {
 	CronRule   string              `json:"cronRule"`
@@ -97,26 +118,74 @@ type Recurrent struct {
 
 // RecurrentProperties additional settings for recurrence
 type RecurrentProperties struct {
-	FailedCount int     `json:"failedCount"`
-	FailedRule  string  `json:"failedRule"`
-	Amount      float64 `json:"amount"`
-	UrlNotify   string  `json:"UrlNotify"`
+	FailedCount int            `json:"failedCount"`
+	FailedRule  string         `json:"failedRule"`
+	Amount      currency.Money `json:"amount"`
+	UrlNotify   string         `json:"UrlNotify"`
 }
 
+type SplitUnit string
+
+const (
+	SplitUnitAmount  SplitUnit = "amount"
+	SplitUnitPercent SplitUnit = "percent"
+)
+
 // Splitting information for dividing the payment
 type Splitting struct {
 	Items []SplitItem `json:"items"`
 }
 
-// SplitItem details for each split
+// SplitItem details for each split, its value is Amount for amount splits and Percent for percent splits
 type SplitItem struct {
-	ServiceKey       string         `json:"serviceKey"`
-	OrderID          string         `json:"orderId"`
-	BankingDetailsID string         `json:"bankingDetailsId"`
-	BankingDetails   BankingDetails `json:"bankingDetails"`
-	Unit             string         `json:"unit"`
-	Value            float64        `json:"value"`
-	WithCommission   bool           `json:"withCommission"`
+	ServiceKey       string          `json:"serviceKey"`
+	OrderID          string          `json:"orderId,omitempty"`
+	BankingDetailsID string          `json:"bankingDetailsId,omitempty"`
+	BankingDetails   *BankingDetails `json:"bankingDetails,omitempty"`
+	Unit             SplitUnit       `json:"unit"`
+	Amount           currency.Money  `json:"-"`
+	Percent          float64         `json:"-"`
+	WithCommission   bool            `json:"withCommission"`
+}
+
+// splitItem has the fields of SplitItem without its JSON methods
+type splitItem SplitItem
+
+// MarshalJSON sends Amount or Percent as the value of the split, depending on its unit
+func (i SplitItem) MarshalJSON() ([]byte, error) {
+	var value any = i.Percent
+	if i.Unit == SplitUnitAmount {
+		value = i.Amount
+	}
+
+	return json.Marshal(
+		struct {
+			splitItem
+			Value any `json:"value"`
+		}{splitItem(i), value},
+	)
+}
+
+// UnmarshalJSON reads the value of the split into Amount or Percent, depending on its unit
+func (i *SplitItem) UnmarshalJSON(data []byte) error {
+	var item struct {
+		splitItem
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+
+	*i = SplitItem(item.splitItem)
+	if len(item.Value) == 0 {
+		return nil
+	}
+
+	if i.Unit == SplitUnitAmount {
+		return json.Unmarshal(item.Value, &i.Amount)
+	}
+
+	return json.Unmarshal(item.Value, &i.Percent)
 }
 
 // UserPaymentInstrument payment method details
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"html"
 	"strings"
+
+	"github.com/stremovskyy/go-easypay/currency"
 )
 
 type Status string
@@ -21,6 +23,42 @@ const (
 	PaymentHold             Status = "paymenthold"
 )
 
+// knownStatuses lists the states that ParseStatus normalises to
+var knownStatuses = []Status{
+	StatusConfirmed,
+	StatusRejected,
+	StatusWaitVerify,
+	StatusPending,
+	StatusRefunded,
+	StatusWaitConfirm,
+	StatusCancelingAccepted,
+	StatusCancelingDeclined,
+	PaymentHold,
+}
+
+// ParseStatus matches the state case-insensitively against the known Status values,
+// unknown states are returned as is
+func ParseStatus(state string) Status {
+	for _, status := range knownStatuses {
+		if strings.EqualFold(state, string(status)) {
+			return status
+		}
+	}
+
+	return Status(state)
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var state string
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	*s = ParseStatus(state)
+
+	return nil
+}
+
 type Response struct {
 	PaymentState            Status                  `json:"paymentState"`
 	ActionType              string                  `json:"actionType"`
@@ -44,7 +82,8 @@ type Response struct {
 	MerchantKey             *string                 `json:"merchantKey,omitempty"`
 	RefundTransactionId     *int                    `json:"refundTransactionId,omitempty"`
 	OrderId                 *string                 `json:"orderId,omitempty"`
-	Amount                  *float64                `json:"amount,omitempty"`
+	Amount                  *currency.Money         `json:"amount,omitempty"`
+	PaymentsList            []PaymentDetail         `json:"paymentsList,omitempty"`
 }
 
 // ResponseItems could contain various details specific to the transaction
@@ -67,8 +106,8 @@ type ResponseItems struct {
 type PaymentInstrumentType struct {
 	InstrumentType         string                         `json:"instrumentType"`
 	Commission             float64                        `json:"commission"`
-	AmountMin              float64                        `json:"amountMin"`
-	AmountMax              float64                        `json:"amountMax"`
+	AmountMin              currency.Money                 `json:"amountMin"`
+	AmountMax              currency.Money                 `json:"amountMax"`
 	UserPaymentInstruments []UserPaymentInstrumentDetails `json:"userPaymentInstruments"`
 }
 
@@ -87,22 +126,20 @@ type UserPaymentInstrumentDetails struct {
 
 // Error structure to encapsulate API error details
 type Error struct {
-	ErrorCode       *string `json:"errorCode"`
-	ClientErrorCode *string `json:"clientErrorCode"`
-	Title           *string `json:"title"`
-	Description     *string `json:"description"`
-	ErrorMessage    *string `json:"errorMessage"`
-	FieldErrors     []struct {
-		FieldName    string      `json:"fieldName"`
-		ErrorCode    interface{} `json:"errorCode"`
-		ErrorMessage string      `json:"errorMessage"`
-	} `json:"fieldErrors"`
+	ErrorCode       *string      `json:"errorCode"`
+	ClientErrorCode *string      `json:"clientErrorCode"`
+	Title           *string      `json:"title"`
+	Description     *string      `json:"description"`
+	ErrorMessage    *string      `json:"errorMessage"`
+	FieldErrors     []FieldError `json:"fieldErrors"`
 }
 
 // GetError returns a constructed error based on the response error details
 // CustomError is a custom type for formatting errors from the Response struct.
 type CustomError struct {
 	Resp *Response
+	// codes are the error codes of the client that received the error
+	codes ErrorCodes
 }
 
 func (ce *CustomError) Error() string {
@@ -137,6 +174,14 @@ func (ce *CustomError) Error() string {
 	return strings.TrimRight(sb.String(), ", ")
 }
 
+// APIResponse is implemented by every response model that can carry an API error
+type APIResponse interface {
+	GetError() error
+	// Reset sets the response to its zero value. The client calls it before every attempt of a request,
+	// so a retry is not decoded over the fields of a failed attempt.
+	Reset()
+}
+
 func (r *Response) GetError() error {
 	if r.Error != nil {
 		return &CustomError{Resp: r}
@@ -144,6 +189,15 @@ func (r *Response) GetError() error {
 	return nil
 }
 
+func (r *Response) Reset() {
+	*r = Response{}
+}
+
+// SplitResults returns the state of every split part keyed by its merchant service key
+func (r *Response) SplitResults() map[string]PaymentDetail {
+	return splitResults(r.PaymentsList)
+}
+
 func (r *Response) App() *App {
 	return NewApp(r.LogoPath, r.ApiVersion, r.AppId)
 }
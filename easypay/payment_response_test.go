@@ -0,0 +1,21 @@
+package easypay
+
+import "testing"
+
+func TestUnmarshalJSONResponse(t *testing.T) {
+	response, err := UnmarshalJSONResponse([]byte(`{"paymentState":"confirmed","orderId":"order-1","error":null}`))
+	if err != nil {
+		t.Fatalf("UnmarshalJSONResponse error = %v", err)
+	}
+
+	if response.PaymentState != StatusConfirmed || response.OrderId == nil || *response.OrderId != "order-1" {
+		t.Errorf("UnmarshalJSONResponse = %+v, want order-1 confirmed", response)
+	}
+	if err = response.GetError(); err != nil {
+		t.Errorf("GetError() = %v, want nil", err)
+	}
+
+	if _, err = UnmarshalJSONResponse([]byte(`{"paymentState":`)); err == nil {
+		t.Error("UnmarshalJSONResponse of a truncated body succeeded")
+	}
+}
@@ -0,0 +1,12 @@
+package easypay
+
+import "net/url"
+
+// PaymentURLResponse holds the hosted checkout page created for an order
+type PaymentURLResponse struct {
+	URL           *url.URL
+	TransactionID *int64
+	OrderID       *string
+	PaymentState  Status
+	Response      *Response
+}
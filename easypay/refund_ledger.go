@@ -0,0 +1,113 @@
+package easypay
+
+import (
+	"fmt"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
+// RefundEntry is a refund transaction made for an order
+type RefundEntry struct {
+	RefundTransactionID int64
+	Amount              currency.Money
+	Date                string
+}
+
+// RefundLedger tracks how much of a captured order was refunded
+type RefundLedger struct {
+	OrderID       string
+	TransactionID int64
+	Captured      currency.Money
+	Refunds       []RefundEntry
+}
+
+// RefundLedger builds the ledger of the order from its state, child payments in another currency than
+// the order are reported with currency.ErrCurrencyMismatch
+func (r *PaymentStatusResponse) RefundLedger() (*RefundLedger, error) {
+	ledger := &RefundLedger{
+		OrderID:       r.OrderID,
+		TransactionID: r.TransactionID,
+		Captured:      currency.New(0, r.Amount.Currency()),
+	}
+
+	payments := r.Payments()
+	for _, payment := range payments {
+		if payment.PaymentState == StatusConfirmed || payment.PaymentState == StatusRefunded {
+			captured, err := ledger.Captured.AddChecked(payment.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("payment %d: %w", payment.TransactionID, err)
+			}
+
+			ledger.Captured = captured
+		}
+	}
+
+	// orders without child payments are captured as a whole
+	if len(payments) == 0 && (r.PaymentState == StatusConfirmed || r.PaymentState == StatusRefunded) {
+		ledger.Captured = r.Amount
+	}
+
+	for _, refund := range r.Refunds() {
+		ledger.Refunds = append(
+			ledger.Refunds, RefundEntry{
+				RefundTransactionID: refund.RefundTransactionID,
+				Amount:              refund.Amount,
+				Date:                refund.Date,
+			},
+		)
+	}
+
+	// a refunded order without listed refunds has nothing left to refund
+	if len(ledger.Refunds) == 0 && r.PaymentState == StatusRefunded {
+		ledger.Record(0, ledger.Captured)
+	}
+
+	return ledger, nil
+}
+
+// Record adds a refund to the ledger, a refund transaction already in the ledger is not added twice
+func (l *RefundLedger) Record(refundTransactionID int64, amount currency.Money) {
+	if refundTransactionID != 0 {
+		for _, refund := range l.Refunds {
+			if refund.RefundTransactionID == refundTransactionID {
+				return
+			}
+		}
+	}
+
+	l.Refunds = append(l.Refunds, RefundEntry{RefundTransactionID: refundTransactionID, Amount: amount})
+}
+
+// Refunded returns the sum of all refunds, currency.ErrCurrencyMismatch when a refund is in another currency
+func (l *RefundLedger) Refunded() (currency.Money, error) {
+	refunded := currency.New(0, l.Captured.Currency())
+	for _, refund := range l.Refunds {
+		sum, err := refunded.AddChecked(refund.Amount)
+		if err != nil {
+			return currency.Money{}, fmt.Errorf("refund %d: %w", refund.RefundTransactionID, err)
+		}
+
+		refunded = sum
+	}
+
+	return refunded, nil
+}
+
+// Remaining returns the amount that can still be refunded
+func (l *RefundLedger) Remaining() (currency.Money, error) {
+	refunded, err := l.Refunded()
+	if err != nil {
+		return currency.Money{}, err
+	}
+
+	remaining, err := l.Captured.SubChecked(refunded)
+	if err != nil {
+		return currency.Money{}, err
+	}
+
+	if remaining.IsNegative() {
+		return currency.New(0, remaining.Currency()), nil
+	}
+
+	return remaining, nil
+}
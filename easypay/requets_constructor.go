@@ -26,6 +26,7 @@ package easypay
 
 import (
 	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
 	"github.com/stremovskyy/go-easypay/internal/utils"
 )
 
@@ -42,7 +43,7 @@ func NewRequest(url string, options ...func(request *Request)) *Request {
 	return rw
 }
 
-func WithAmount(a float64) func(*Request) {
+func WithAmount(a currency.Money) func(*Request) {
 	return func(rw *Request) {
 		if rw.Order == nil {
 			rw.Order = &Order{}
@@ -51,8 +52,27 @@ func WithAmount(a float64) func(*Request) {
 		rw.Order.Amount = &a
 	}
 }
-func WithRootAmount(a float64) func(*Request) {
+func WithCurrency(code currency.Code) func(*Request) {
 	return func(rw *Request) {
+		if code == "" {
+			return
+		}
+
+		if rw.Order == nil {
+			rw.Order = &Order{}
+		}
+
+		rw.Order.Currency = utils.Ref(code.String())
+	}
+}
+
+// WithRootAmount sets the amount of a capture or a refund, a zero amount is left out so the whole order is affected
+func WithRootAmount(a currency.Money) func(*Request) {
+	return func(rw *Request) {
+		if a.IsZero() {
+			return
+		}
+
 		rw.Amount = &a
 	}
 }
@@ -111,6 +131,10 @@ func WithWebhook(url *string) func(request *Request) {
 
 func WithAdditionalWebhook(url *string) func(request *Request) {
 	return func(rw *Request) {
+		if url == nil {
+			return
+		}
+
 		if rw.Order == nil {
 			rw.Order = &Order{
 				AdditionalItems: utils.Ref(make(map[string]string)),
@@ -210,11 +234,44 @@ func WithCardTokenID(id string) func(request *Request) {
 
 func WithBankingDetails(details *BankingDetails) func(request *Request) {
 	return func(rw *Request) {
-		if rw.UserPaymentInstrument == nil {
-			rw.UserPaymentInstrument = &UserPaymentInstrument{}
+		rw.BankingDetails = details
+	}
+}
+
+func WithPayee(id string, name string) func(request *Request) {
+	return func(rw *Request) {
+		if rw.BankingDetails == nil {
+			rw.BankingDetails = &BankingDetails{}
 		}
 
-		rw.BankingDetails = details
+		if rw.BankingDetails.Payee == nil {
+			rw.BankingDetails.Payee = &Payee{}
+		}
+
+		rw.BankingDetails.Payee.ID = id
+		rw.BankingDetails.Payee.Name = name
+	}
+}
+
+func WithNarrative(narrative string) func(request *Request) {
+	return func(rw *Request) {
+		if rw.BankingDetails == nil {
+			rw.BankingDetails = &BankingDetails{}
+		}
+
+		rw.BankingDetails.Narrative = &Narrative{Name: narrative}
+	}
+}
+
+func WithSplitting(splitting *Splitting) func(request *Request) {
+	return func(rw *Request) {
+		rw.Splitting = splitting
+	}
+}
+
+func WithRecurrent(recurrent *Recurrent) func(request *Request) {
+	return func(rw *Request) {
+		rw.Recurrent = recurrent
 	}
 }
 
@@ -261,3 +318,23 @@ func WithoutError() func(request *Request) {
 		rw.SkipGeneratingError = true
 	}
 }
+
+func WithOperation(operation Operation) func(request *Request) {
+	return func(rw *Request) {
+		rw.Operation = operation
+	}
+}
+
+func WithRetry() func(request *Request) {
+	return func(rw *Request) {
+		rw.Retryable = true
+	}
+}
+
+func WithIdempotencyKey(key *string) func(request *Request) {
+	return func(rw *Request) {
+		if key != nil {
+			rw.IdempotencyKey = *key
+		}
+	}
+}
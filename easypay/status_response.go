@@ -1,15 +1,17 @@
 package easypay
 
+import "github.com/stremovskyy/go-easypay/currency"
+
 // PaymentDetail holds information about individual payments in a split payment or detailed transaction list
 type PaymentDetail struct {
-	MerchantKey         string  `json:"merchantKey"`
-	TransactionID       int64   `json:"transactionId"`
-	OrderID             string  `json:"orderId"`
-	Amount              float64 `json:"amount"`
-	PaymentState        string  `json:"paymentState"`
-	RefundTransactionID int64   `json:"refundTransactionId,omitempty"`
-	Date                string  `json:"date,omitempty"`
-	Error               *Error  `json:"error,omitempty"`
+	MerchantKey         string         `json:"merchantKey"`
+	TransactionID       int64          `json:"transactionId"`
+	OrderID             string         `json:"orderId"`
+	Amount              currency.Money `json:"amount"`
+	PaymentState        Status         `json:"paymentState"`
+	RefundTransactionID int64          `json:"refundTransactionId,omitempty"`
+	Date                string         `json:"date,omitempty"`
+	Error               *Error         `json:"error,omitempty"`
 }
 
 // PaymentStatusResponse is the structure for the payment status response
@@ -17,9 +19,69 @@ type PaymentStatusResponse struct {
 	MerchantKey         string          `json:"merchantKey"`
 	TransactionID       int64           `json:"transactionId"`
 	OrderID             string          `json:"orderId"`
-	Amount              float64         `json:"amount"`
-	PaymentState        string          `json:"paymentState"`
+	Amount              currency.Money  `json:"amount"`
+	PaymentState        Status          `json:"paymentState"`
 	RefundTransactionID int64           `json:"refundTransactionId,omitempty"`
 	Error               *Error          `json:"error"`
 	PaymentsList        []PaymentDetail `json:"paymentsList,omitempty"`
 }
+
+// SplitResults returns the state of every split part keyed by its merchant service key
+func (r *PaymentStatusResponse) SplitResults() map[string]PaymentDetail {
+	return splitResults(r.PaymentsList)
+}
+
+func splitResults(payments []PaymentDetail) map[string]PaymentDetail {
+	results := make(map[string]PaymentDetail, len(payments))
+	for _, payment := range payments {
+		results[payment.MerchantKey] = payment
+	}
+
+	return results
+}
+
+func (r *PaymentStatusResponse) GetError() error {
+	if r.Error != nil {
+		return &CustomError{Resp: &Response{Error: r.Error}}
+	}
+
+	return nil
+}
+
+func (r *PaymentStatusResponse) Reset() {
+	*r = PaymentStatusResponse{}
+}
+
+// Payments returns the child payments of the order, without refunds
+func (r *PaymentStatusResponse) Payments() []PaymentDetail {
+	var payments []PaymentDetail
+	for _, payment := range r.PaymentsList {
+		if !payment.IsRefund() {
+			payments = append(payments, payment)
+		}
+	}
+
+	return payments
+}
+
+// Refunds returns the refund transactions made for the order
+func (r *PaymentStatusResponse) Refunds() []PaymentDetail {
+	var refunds []PaymentDetail
+	for _, payment := range r.PaymentsList {
+		if payment.IsRefund() {
+			refunds = append(refunds, payment)
+		}
+	}
+
+	return refunds
+}
+
+// IsHeld reports whether the order amount is held and waits for capture
+func (r *PaymentStatusResponse) IsHeld() bool {
+	return r.PaymentState == PaymentHold
+}
+
+// IsRefund reports whether the detail describes a refund transaction
+func (d PaymentDetail) IsRefund() bool {
+	return d.RefundTransactionID != 0
+}
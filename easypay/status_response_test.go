@@ -0,0 +1,203 @@
+package easypay
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		state string
+		want  Status
+	}{
+		{state: "Confirmed", want: StatusConfirmed},
+		{state: "confirmed", want: StatusConfirmed},
+		{state: "CONFIRMED", want: StatusConfirmed},
+		{state: "Rejected", want: StatusRejected},
+		{state: "waitverify", want: StatusWaitVerify},
+		{state: "Pending", want: StatusPending},
+		{state: "refunded", want: StatusRefunded},
+		{state: "WaitConfirm", want: StatusWaitConfirm},
+		{state: "Accepted", want: StatusCancelingAccepted},
+		{state: "declined", want: StatusCancelingDeclined},
+		{state: "PaymentHold", want: PaymentHold},
+		{state: "paymenthold", want: PaymentHold},
+		{state: "Reversed", want: Status("Reversed")},
+		{state: "", want: Status("")},
+	}
+
+	for _, tt := range tests {
+		if got := ParseStatus(tt.state); got != tt.want {
+			t.Errorf("ParseStatus(%q) = %q, want %q", tt.state, got, tt.want)
+		}
+
+		var decoded Status
+		if err := json.Unmarshal([]byte(`"`+tt.state+`"`), &decoded); err != nil || decoded != tt.want {
+			t.Errorf("Unmarshal(%q) = %q, %v, want %q", tt.state, decoded, err, tt.want)
+		}
+	}
+}
+
+// orderState decodes the orderState response fixture, the fixtures follow the orderState fields
+// the client reads and mix the number, string and null values Easypay sends
+func orderState(t *testing.T, name string) *PaymentStatusResponse {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ReadFile error = %v", err)
+	}
+
+	state := &PaymentStatusResponse{}
+	if err = json.Unmarshal(raw, state); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", name, err)
+	}
+
+	return state
+}
+
+func TestPaymentStatusResponseSplits(t *testing.T) {
+	state := orderState(t, "order_state_split.json")
+
+	if state.PaymentState != StatusConfirmed || state.OrderID != "order-1001" || state.TransactionID != 823451901 || state.Amount.MinorUnits() != 15000 {
+		t.Errorf("order = %s %s %d %s, want the confirmed order-1001", state.OrderID, state.PaymentState, state.TransactionID, state.Amount)
+	}
+
+	if err := state.GetError(); err != nil {
+		t.Errorf("GetError() = %v, want nil", err)
+	}
+
+	if refunds := state.Refunds(); len(refunds) != 0 {
+		t.Errorf("Refunds() = %+v, want none", refunds)
+	}
+
+	results := state.SplitResults()
+	if len(results) != 2 || len(state.Payments()) != 2 {
+		t.Fatalf("SplitResults() = %+v, want both parts", results)
+	}
+
+	want := PaymentDetail{
+		MerchantKey:   "easypay-shop-a",
+		TransactionID: 823451902,
+		OrderID:       "order-1001-a",
+		Amount:        currency.New(10000, ""),
+		PaymentState:  StatusConfirmed,
+		Date:          "2024-05-01T10:20:30",
+	}
+	if got := results["easypay-shop-a"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("split easypay-shop-a = %+v, want %+v", got, want)
+	}
+
+	rejected := results["easypay-shop-b"]
+	if rejected.PaymentState != StatusRejected || rejected.Amount.MinorUnits() != 5000 || rejected.Error == nil || *rejected.Error.ErrorCode != CodeCardDeclined {
+		t.Errorf("split easypay-shop-b = %+v, want it rejected with %s", rejected, CodeCardDeclined)
+	}
+
+	// the rejected part was not captured
+	if remaining := remainingAmount(t, state); remaining.MinorUnits() != 10000 {
+		t.Errorf("remaining refundable amount = %s, want 100.00", remaining)
+	}
+}
+
+func TestPaymentStatusResponseRefunds(t *testing.T) {
+	state := orderState(t, "order_state_refunded.json")
+
+	if state.PaymentState != StatusRefunded || state.IsHeld() {
+		t.Errorf("order is %s, want %s", state.PaymentState, StatusRefunded)
+	}
+
+	payments := state.Payments()
+	if len(payments) != 1 || payments[0].PaymentState != StatusConfirmed || payments[0].Amount.MinorUnits() != 20000 || payments[0].IsRefund() {
+		t.Errorf("Payments() = %+v, want the confirmed 200.00", payments)
+	}
+
+	refunds := state.Refunds()
+	if len(refunds) != 2 {
+		t.Fatalf("Refunds() = %+v, want 2 refunds", refunds)
+	}
+
+	for i, want := range []struct {
+		id     int64
+		amount int64
+		date   string
+	}{{id: 823451911, amount: 7550, date: "2024-05-03T12:00:00"}, {id: 823451912, amount: 12450, date: "2024-05-04T12:00:00"}} {
+		refund := refunds[i]
+		if !refund.IsRefund() || refund.RefundTransactionID != want.id || refund.Amount.MinorUnits() != want.amount || refund.Date != want.date {
+			t.Errorf("refund %d = %+v, want %d of %d minor units on %s", i, refund, want.id, want.amount, want.date)
+		}
+	}
+
+	ledger, err := state.RefundLedger()
+	if err != nil {
+		t.Fatalf("RefundLedger() error = %v", err)
+	}
+
+	refunded, err := ledger.Refunded()
+	if err != nil {
+		t.Fatalf("Refunded() error = %v", err)
+	}
+
+	if remaining := remainingAmount(t, state); ledger.Captured.MinorUnits() != 20000 || refunded.MinorUnits() != 20000 || !remaining.IsZero() {
+		t.Errorf("ledger captured %s, refunded %s, remaining %s, want everything refunded", ledger.Captured, refunded, remaining)
+	}
+}
+
+func TestRefundLedgerCurrencyMismatch(t *testing.T) {
+	ledger := &RefundLedger{Captured: currency.New(1000, currency.UAH)}
+	ledger.Record(1, currency.New(100, currency.USD))
+
+	if _, err := ledger.Refunded(); !errors.Is(err, currency.ErrCurrencyMismatch) {
+		t.Errorf("Refunded() error = %v, want %v", err, currency.ErrCurrencyMismatch)
+	}
+	if _, err := ledger.Remaining(); !errors.Is(err, currency.ErrCurrencyMismatch) {
+		t.Errorf("Remaining() error = %v, want %v", err, currency.ErrCurrencyMismatch)
+	}
+}
+
+// remainingAmount is the refundable amount of the order in the state
+func remainingAmount(t *testing.T, state *PaymentStatusResponse) currency.Money {
+	t.Helper()
+
+	ledger, err := state.RefundLedger()
+	if err != nil {
+		t.Fatalf("RefundLedger() error = %v", err)
+	}
+
+	remaining, err := ledger.Remaining()
+	if err != nil {
+		t.Fatalf("Remaining() error = %v", err)
+	}
+
+	return remaining
+}
+
+func TestPaymentStatusResponseHold(t *testing.T) {
+	state := orderState(t, "order_state_hold.json")
+
+	if !state.IsHeld() || state.Amount.MinorUnits() != 9999 || state.PaymentsList != nil {
+		t.Errorf("order = %+v, want 99.99 held without payments", state)
+	}
+
+	if remaining := remainingAmount(t, state); !remaining.IsZero() {
+		t.Errorf("remaining refundable amount of a hold = %s, want 0.00", remaining)
+	}
+}
+
+func TestPaymentStatusResponseError(t *testing.T) {
+	state := orderState(t, "order_state_not_found.json")
+
+	err := state.GetError()
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("GetError() = %v, want %v", err, ErrOrderNotFound)
+	}
+
+	if state.PaymentState != "" || state.OrderID != "" {
+		t.Errorf("order = %s %s, want the null fields empty", state.OrderID, state.PaymentState)
+	}
+}
@@ -0,0 +1,53 @@
+package easypay
+
+import "github.com/stremovskyy/go-easypay/currency"
+
+// VoidHoldResponse is the structure for the response from releasing a held order
+type VoidHoldResponse struct {
+	MerchantKey   string `json:"merchantKey"`
+	TransactionID int64  `json:"transactionId"`
+	OrderID       string `json:"orderId"`
+	// Released is the held amount returned to the card
+	Released     currency.Money `json:"amount"`
+	PaymentState Status         `json:"paymentState"`
+	Error        *Error         `json:"error"`
+}
+
+// NewVoidHoldResponse takes the cancellation fields of a generic response, released is the amount that was held
+func NewVoidHoldResponse(r *Response, released currency.Money) *VoidHoldResponse {
+	response := &VoidHoldResponse{
+		Released:     released,
+		PaymentState: r.PaymentState,
+		Error:        r.Error,
+	}
+
+	if r.MerchantKey != nil {
+		response.MerchantKey = *r.MerchantKey
+	}
+	if r.TransactionId != nil {
+		response.TransactionID = *r.TransactionId
+	}
+	if r.OrderId != nil {
+		response.OrderID = *r.OrderId
+	}
+
+	return response
+}
+
+func (r *VoidHoldResponse) GetError() error {
+	if r.Error != nil {
+		return &CustomError{Resp: &Response{Error: r.Error}}
+	}
+
+	return nil
+}
+
+func (r *VoidHoldResponse) Reset() {
+	*r = VoidHoldResponse{}
+}
+
+// IsAccepted reports whether Easypay accepted releasing the hold, a void resolved from the order state
+// reports the state of the released order
+func (r *VoidHoldResponse) IsAccepted() bool {
+	return r.PaymentState == StatusCancelingAccepted || r.PaymentState == StatusRefunded
+}
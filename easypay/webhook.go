@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
 )
 
 type Webhook struct {
@@ -25,11 +27,38 @@ type Webhook struct {
 	Additionalitems *Additionalitems `json:"additionalitems"`
 }
 
+// UnmarshalJSON decodes the notification, its date is read with any of the layouts seen in Easypay notifications
+func (w *Webhook) UnmarshalJSON(data []byte) error {
+	type webhook Webhook
+
+	raw := struct {
+		*webhook
+		Date string `json:"date"`
+	}{webhook: (*webhook)(w)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	w.Date = time.Time{}
+	if raw.Date == "" {
+		return nil
+	}
+
+	date, err := parseWebhookDate(raw.Date)
+	if err != nil {
+		return err
+	}
+	w.Date = date
+
+	return nil
+}
+
 type WebhookDetails struct {
-	Amount      int         `json:"amount"`
-	Desc        string      `json:"desc"`
-	PaymentId   int         `json:"payment_id"`
-	RecurrentId interface{} `json:"recurrent_id"`
+	Amount      currency.Money `json:"amount"`
+	Desc        string         `json:"desc"`
+	PaymentId   int            `json:"payment_id"`
+	RecurrentId interface{}    `json:"recurrent_id"`
 }
 
 type Additionalitems struct {
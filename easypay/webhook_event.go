@@ -0,0 +1,374 @@
+package easypay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
+type EventType string
+
+const (
+	EventTypeCardTokenized EventType = "card_tokenized"
+	EventTypePayment       EventType = "payment"
+	EventTypeHold          EventType = "hold"
+	EventTypeRefund        EventType = "refund"
+	EventTypeRecurrent     EventType = "recurrent"
+	EventTypeUnknown       EventType = "unknown"
+)
+
+// webhookDateLayouts are the date formats seen in Easypay notifications
+var webhookDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"02.01.2006 15:04:05",
+}
+
+// Event is a typed Easypay notification
+type Event interface {
+	Type() EventType
+	// Webhook returns the notification as parsed by ParseWebhook
+	Webhook() *Webhook
+}
+
+// CardTokenizedEvent is sent when a card is saved through a verification link
+type CardTokenizedEvent struct {
+	PartnerKey string
+	// Phone holds the payment ID the verification link was created for
+	Phone          string
+	CardGuid       string
+	Pan            string
+	ExpireMonth    int
+	ExpireYear     int
+	DatePost       time.Time
+	CodeType       string
+	CardLabel      *string
+	ExistingTokens []string
+
+	raw *Webhook
+}
+
+func (e *CardTokenizedEvent) Type() EventType {
+	return EventTypeCardTokenized
+}
+
+func (e *CardTokenizedEvent) Webhook() *Webhook {
+	return e.raw
+}
+
+// PaymentEventData holds the fields shared by every order notification
+type PaymentEventData struct {
+	Action      string
+	MerchantID  int
+	OrderID     string
+	PaymentID   int64
+	Amount      currency.Money
+	Description string
+	RecurrentID *int64
+	Version     string
+	Date        time.Time
+	Items       *Additionalitems
+
+	raw *Webhook
+}
+
+func (e *PaymentEventData) Webhook() *Webhook {
+	return e.raw
+}
+
+// PaymentEvent is sent when an order is paid
+type PaymentEvent struct {
+	PaymentEventData
+}
+
+func (e *PaymentEvent) Type() EventType {
+	return EventTypePayment
+}
+
+// HoldEvent is sent when an order amount is held on the card
+type HoldEvent struct {
+	PaymentEventData
+}
+
+func (e *HoldEvent) Type() EventType {
+	return EventTypeHold
+}
+
+// RefundEvent is sent when an order is refunded or cancelled
+type RefundEvent struct {
+	PaymentEventData
+}
+
+func (e *RefundEvent) Type() EventType {
+	return EventTypeRefund
+}
+
+// RecurrentEvent is sent for every charge of a recurrent payment
+type RecurrentEvent struct {
+	PaymentEventData
+}
+
+func (e *RecurrentEvent) Type() EventType {
+	return EventTypeRecurrent
+}
+
+// UnknownEvent wraps notifications of an unrecognised kind
+type UnknownEvent struct {
+	raw *Webhook
+}
+
+func (e *UnknownEvent) Type() EventType {
+	return EventTypeUnknown
+}
+
+func (e *UnknownEvent) Webhook() *Webhook {
+	return e.raw
+}
+
+// ParseEvent parses the notification and returns the typed event of its kind
+func ParseEvent(body []byte) (Event, error) {
+	w, err := ParseWebhook(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEvent(w)
+}
+
+// NewEvent converts a parsed notification to the typed event of its kind
+func NewEvent(w *Webhook) (Event, error) {
+	eventType := DetectEventType(w)
+
+	if eventType == EventTypeCardTokenized {
+		return newCardTokenizedEvent(w)
+	}
+
+	if eventType == EventTypeUnknown {
+		return &UnknownEvent{raw: w}, nil
+	}
+
+	data, err := newPaymentEventData(w)
+	if err != nil {
+		return nil, err
+	}
+
+	switch eventType {
+	case EventTypeHold:
+		return &HoldEvent{PaymentEventData: *data}, nil
+	case EventTypeRefund:
+		return &RefundEvent{PaymentEventData: *data}, nil
+	case EventTypeRecurrent:
+		return &RecurrentEvent{PaymentEventData: *data}, nil
+	default:
+		return &PaymentEvent{PaymentEventData: *data}, nil
+	}
+}
+
+// eventTypesByAction maps the actions of Easypay notifications to event types, actions are matched exactly
+var eventTypesByAction = map[string]EventType{
+	"payment":   EventTypePayment,
+	"unhold":    EventTypePayment, // capture of a held payment
+	"capture":   EventTypePayment,
+	"hold":      EventTypeHold,
+	"refund":    EventTypeRefund,
+	"cancel":    EventTypeRefund, // a cancelled hold is released to the card
+	"void":      EventTypeRefund,
+	"reverse":   EventTypeRefund,
+	"reversal":  EventTypeRefund,
+	"recurrent": EventTypeRecurrent,
+	"reccurent": EventTypeRecurrent,
+}
+
+// DetectEventType classifies the notification by its action, recurrent ID and tokenization fields
+func DetectEventType(w *Webhook) EventType {
+	action := strings.ToLower(strings.TrimSpace(w.Action))
+	hasRecurrentID := w.Details != nil && w.Details.RecurrentId != nil
+
+	if eventType, ok := eventTypesByAction[action]; ok {
+		// payments charged by a recurrent schedule carry its ID
+		if eventType == EventTypePayment && hasRecurrentID {
+			return EventTypeRecurrent
+		}
+
+		return eventType
+	}
+
+	switch {
+	case hasRecurrentID:
+		return EventTypeRecurrent
+	case action == "" && w.CardGuid != "":
+		return EventTypeCardTokenized
+	default:
+		return EventTypeUnknown
+	}
+}
+
+func newCardTokenizedEvent(w *Webhook) (*CardTokenizedEvent, error) {
+	event := &CardTokenizedEvent{
+		PartnerKey: w.PartnerKey,
+		Phone:      w.Phone,
+		CardGuid:   w.CardGuid,
+		Pan:        w.Pan,
+		CodeType:   w.CodeType,
+		CardLabel:  w.CardLabel,
+		raw:        w,
+	}
+
+	var err error
+	if w.Expire != "" {
+		event.ExpireMonth, event.ExpireYear, err = parseCardExpire(w.Expire)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if w.DatePost != "" {
+		event.DatePost, err = parseWebhookDate(w.DatePost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	event.ExistingTokens, err = parseExistingTokens(w.ExistingTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func newPaymentEventData(w *Webhook) (*PaymentEventData, error) {
+	data := &PaymentEventData{
+		Action:     w.Action,
+		MerchantID: w.MerchantId,
+		OrderID:    w.OrderId,
+		Version:    w.Version,
+		Date:       w.Date,
+		Items:      w.Additionalitems,
+		raw:        w,
+	}
+
+	if w.Details != nil {
+		data.PaymentID = int64(w.Details.PaymentId)
+		data.Amount = w.Details.Amount
+		data.Description = w.Details.Desc
+
+		recurrentID, err := parseRecurrentID(w.Details.RecurrentId)
+		if err != nil {
+			return nil, err
+		}
+		data.RecurrentID = recurrentID
+	}
+
+	return data, nil
+}
+
+// parseCardExpire accepts MM/YY, MM/YYYY, MMYY and YYYY-MM expiry dates
+func parseCardExpire(expire string) (int, int, error) {
+	expire = strings.TrimSpace(expire)
+
+	var month, year string
+	switch {
+	case strings.Contains(expire, "/"):
+		month, year, _ = strings.Cut(expire, "/")
+	case strings.Contains(expire, "-"):
+		year, month, _ = strings.Cut(expire, "-")
+	case len(expire) == 4:
+		month, year = expire[:2], expire[2:]
+	default:
+		return 0, 0, fmt.Errorf("cannot parse card expire %q", expire)
+	}
+
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return 0, 0, fmt.Errorf("cannot parse card expire %q", expire)
+	}
+
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse card expire %q", expire)
+	}
+
+	if y < 100 {
+		y += 2000
+	}
+
+	return m, y, nil
+}
+
+func parseWebhookDate(value string) (time.Time, error) {
+	for _, layout := range webhookDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse webhook date %q", value)
+}
+
+func parseRecurrentID(value interface{}) (*int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		id := int64(v)
+		return &id, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse recurrent ID %q", v)
+		}
+
+		return &id, nil
+	default:
+		return nil, fmt.Errorf("unexpected recurrent ID %v", v)
+	}
+}
+
+// parseExistingTokens accepts a list of tokens, a list of objects with a cardGuid or a single token
+func parseExistingTokens(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	if err = json.Unmarshal(raw, &tokens); err == nil {
+		return tokens, nil
+	}
+
+	var cards []struct {
+		CardGuid string `json:"cardGuid"`
+	}
+	if err = json.Unmarshal(raw, &cards); err == nil {
+		for _, card := range cards {
+			tokens = append(tokens, card.CardGuid)
+		}
+
+		return tokens, nil
+	}
+
+	var token string
+	if err = json.Unmarshal(raw, &token); err == nil {
+		if token == "" {
+			return nil, nil
+		}
+
+		return []string{token}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected existing tokens %s", raw)
+}
@@ -0,0 +1,90 @@
+package easypay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWebhookDate(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    time.Time
+		wantErr bool
+	}{
+		{body: `{"date": "2024-05-01T10:20:30+03:00"}`, want: time.Date(2024, 5, 1, 7, 20, 30, 0, time.UTC)},
+		{body: `{"date": "2024-05-01T10:20:30.123"}`, want: time.Date(2024, 5, 1, 10, 20, 30, 123000000, time.UTC)},
+		{body: `{"date": "2024-05-01T10:20:30"}`, want: time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)},
+		{body: `{"date": "2024-05-01 10:20:30"}`, want: time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)},
+		{body: `{"date": "01.05.2024 10:20:30"}`, want: time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)},
+		{body: `{"date": ""}`},
+		{body: `{"date": null}`},
+		{body: `{}`},
+		{body: `{"date": "yesterday"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseWebhook([]byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseWebhook(%s) date = %v, want an error", tt.body, w.Date)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseWebhook(%s) error = %v", tt.body, err)
+			continue
+		}
+
+		if !w.Date.Equal(tt.want) {
+			t.Errorf("ParseWebhook(%s) date = %v, want %v", tt.body, w.Date, tt.want)
+		}
+	}
+}
+
+func TestParseWebhookKeepsFields(t *testing.T) {
+	w, err := ParseWebhook([]byte(`{"action": "payment", "order_id": "order-1", "date": "2024-05-01 10:20:30", "details": {"amount": 19.99, "payment_id": 7}}`))
+	if err != nil {
+		t.Fatalf("ParseWebhook error = %v", err)
+	}
+
+	if w.Action != "payment" || w.OrderId != "order-1" {
+		t.Errorf("ParseWebhook action = %q, order = %q", w.Action, w.OrderId)
+	}
+
+	if w.Details == nil || w.Details.Amount.MinorUnits() != 1999 || w.Details.PaymentId != 7 {
+		t.Errorf("ParseWebhook details = %+v", w.Details)
+	}
+}
+
+func TestDetectEventType(t *testing.T) {
+	recurrentID := 12.0
+
+	tests := []struct {
+		name    string
+		webhook *Webhook
+		want    EventType
+	}{
+		{name: "payment", webhook: &Webhook{Action: "payment"}, want: EventTypePayment},
+		{name: "payment in upper case", webhook: &Webhook{Action: "Payment"}, want: EventTypePayment},
+		{name: "hold", webhook: &Webhook{Action: "hold"}, want: EventTypeHold},
+		{name: "capture of a hold", webhook: &Webhook{Action: "unhold"}, want: EventTypePayment},
+		{name: "capture", webhook: &Webhook{Action: "capture"}, want: EventTypePayment},
+		{name: "refund", webhook: &Webhook{Action: "refund"}, want: EventTypeRefund},
+		{name: "void of a hold", webhook: &Webhook{Action: "cancel"}, want: EventTypeRefund},
+		{name: "void", webhook: &Webhook{Action: "void"}, want: EventTypeRefund},
+		{name: "reversal", webhook: &Webhook{Action: "reverse"}, want: EventTypeRefund},
+		{name: "recurrent", webhook: &Webhook{Action: "recurrent"}, want: EventTypeRecurrent},
+		{name: "recurrent payment", webhook: &Webhook{Action: "payment", Details: &WebhookDetails{RecurrentId: recurrentID}}, want: EventTypeRecurrent},
+		{name: "refund of a recurrent payment", webhook: &Webhook{Action: "refund", Details: &WebhookDetails{RecurrentId: recurrentID}}, want: EventTypeRefund},
+		{name: "card tokenized", webhook: &Webhook{CardGuid: "guid"}, want: EventTypeCardTokenized},
+		{name: "action containing hold", webhook: &Webhook{Action: "holder"}, want: EventTypeUnknown},
+		{name: "unknown", webhook: &Webhook{Action: "chargeback"}, want: EventTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := DetectEventType(tt.webhook); got != tt.want {
+			t.Errorf("%s: DetectEventType(%q) = %s, want %s", tt.name, tt.webhook.Action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest
+
+import (
+	"net/http"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// Failure scripts the answer to upcoming requests, see Server.Fail
+type Failure struct {
+	// Path limits the failure to one endpoint, such as consts.CreateOrderPath, empty matches every endpoint
+	Path string
+	// ErrorCode answers with an Easypay error, easypay.CodeInternalError when nothing else is set
+	ErrorCode string
+	// StatusCode answers with this HTTP status and Body instead of an Easypay response
+	StatusCode int
+	Body       string
+	// Drop closes the connection without an answer
+	Drop bool
+	// AfterProcessing lets the request take effect before it fails, as when the response is lost on the way back
+	AfterProcessing bool
+	// Times is the number of requests that fail, 1 when zero
+	Times int
+}
+
+// Fail queues a failure, failures are applied in the order they were added
+func (s *Server) Fail(failure Failure) {
+	if failure.Times <= 0 {
+		failure.Times = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &failure)
+}
+
+// nextFailure takes the first failure matching the path, the caller holds the lock
+func (s *Server) nextFailure(path string) *Failure {
+	for i, failure := range s.failures {
+		if failure.Path != "" && failure.Path != path {
+			continue
+		}
+
+		failure.Times--
+		if failure.Times == 0 {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+		}
+
+		applied := *failure
+
+		return &applied
+	}
+
+	return nil
+}
+
+func (f *Failure) write(w http.ResponseWriter) {
+	switch {
+	case f.Drop:
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+	case f.StatusCode != 0:
+		w.WriteHeader(f.StatusCode)
+		_, _ = w.Write([]byte(f.Body))
+	default:
+		code := f.ErrorCode
+		if code == "" {
+			code = easypay.CodeInternalError
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, newAPIError(code, "scripted failure").response())
+	}
+}
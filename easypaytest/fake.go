@@ -0,0 +1,633 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/log"
+)
+
+// fakeURL is the reserved host of the payment and verification links returned by Fake
+const fakeURL = "https://easypay.invalid"
+
+// Method names an Easypay method, see Fake.FailNext
+type Method string
+
+const (
+	MethodVerificationLink Method = "VerificationLink"
+	MethodStatus           Method = "Status"
+	MethodOrderState       Method = "OrderState"
+	MethodPaymentURL       Method = "PaymentURL"
+	MethodPayment          Method = "Payment"
+	MethodHold             Method = "Hold"
+	MethodCapture          Method = "Capture"
+	MethodRefund           Method = "Refund"
+	MethodVoidHold         Method = "VoidHold"
+	MethodCredit           Method = "Credit"
+	MethodCreateRecurrent  Method = "CreateRecurrent"
+)
+
+// operations maps the methods to the operation their requests are validated for
+var operations = map[Method]go_easypay.Operation{
+	MethodVerificationLink: go_easypay.OperationVerification,
+	MethodStatus:           go_easypay.OperationStatus,
+	MethodOrderState:       go_easypay.OperationStatus,
+	MethodPaymentURL:       go_easypay.OperationPaymentURL,
+	MethodPayment:          go_easypay.OperationPayment,
+	MethodHold:             go_easypay.OperationHold,
+	MethodCapture:          go_easypay.OperationCapture,
+	MethodRefund:           go_easypay.OperationRefund,
+	MethodVoidHold:         go_easypay.OperationVoid,
+	MethodCredit:           go_easypay.OperationCredit,
+	MethodCreateRecurrent:  go_easypay.OperationCreateRecurrent,
+}
+
+var _ go_easypay.Easypay = (*Fake)(nil)
+
+// Fake is an in-memory Easypay for unit tests. It keeps orders by PaymentID and EasypayPaymentID
+// and rejects illegal transitions the way the API does.
+type Fake struct {
+	mu           sync.Mutex
+	orders       map[string]*Order
+	transactions map[int64]*Order
+	failures     map[Method][]error
+	hook         func(method Method, request *go_easypay.Request) error
+	exchanges    []*easypay.RecordedExchange
+	lastID       int64
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		orders:       make(map[string]*Order),
+		transactions: make(map[int64]*Order),
+		failures:     make(map[Method][]error),
+		lastID:       100000,
+	}
+}
+
+// FailNext makes the next call of the method return err, queued errors are returned in order
+func (f *Fake) FailNext(method Method, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// FailWith calls hook before every call, a non-nil error is returned instead of processing the call
+func (f *Fake) FailWith(hook func(method Method, request *go_easypay.Request) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.hook = hook
+}
+
+// Order returns a copy of the order created with the payment ID
+func (f *Fake) Order(paymentID string) (Order, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, ok := f.orders[paymentID]
+	if !ok {
+		return Order{}, false
+	}
+
+	snapshot := *order
+	snapshot.Refunds = append([]Refund(nil), order.Refunds...)
+
+	return snapshot, true
+}
+
+// Pay completes an order created by PaymentURL, as if the customer paid on the payment page
+func (f *Fake) Pay(paymentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, ok := f.orders[paymentID]
+	if !ok {
+		return fmt.Errorf("%w: %s", easypay.ErrOrderNotFound, paymentID)
+	}
+
+	if order.State != easypay.StatusPending {
+		return fmt.Errorf("order %s is %s, only pending orders can be paid", paymentID, order.State)
+	}
+
+	order.authorize(false)
+
+	return nil
+}
+
+func (f *Fake) SetLogLevel(levelDebug log.Level) {}
+
+func (f *Fake) VerificationLink(request *go_easypay.Request) (*url.URL, error) {
+	return f.VerificationLinkCtx(context.Background(), request)
+}
+
+func (f *Fake) VerificationLinkCtx(ctx context.Context, request *go_easypay.Request) (*url.URL, error) {
+	if err := f.begin(ctx, MethodVerificationLink, request); err != nil {
+		return nil, err
+	}
+
+	link, err := url.Parse(fakeURL + "/verify/" + uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	f.record(MethodVerificationLink, request, link.String(), nil)
+
+	return link, nil
+}
+
+func (f *Fake) Status(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.StatusCtx(context.Background(), request)
+}
+
+func (f *Fake) StatusCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	if err := f.begin(ctx, MethodStatus, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.find(request)
+	if apiErr != nil {
+		// Status reports API errors in the response
+		response := apiErr.response()
+		f.recordLocked(MethodStatus, request, response, nil)
+
+		return response, nil
+	}
+
+	response := order.response()
+	f.recordLocked(MethodStatus, request, response, nil)
+
+	return response, nil
+}
+
+func (f *Fake) OrderState(request *go_easypay.Request) (*easypay.PaymentStatusResponse, error) {
+	return f.OrderStateCtx(context.Background(), request)
+}
+
+func (f *Fake) OrderStateCtx(ctx context.Context, request *go_easypay.Request) (*easypay.PaymentStatusResponse, error) {
+	if err := f.begin(ctx, MethodOrderState, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.find(request)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodOrderState, request, apiErr.err())
+	}
+
+	state := order.state()
+	f.recordLocked(MethodOrderState, request, state, nil)
+
+	return state, nil
+}
+
+func (f *Fake) PaymentURL(request *go_easypay.Request) (*easypay.PaymentURLResponse, error) {
+	return f.PaymentURLCtx(context.Background(), request)
+}
+
+func (f *Fake) PaymentURLCtx(ctx context.Context, request *go_easypay.Request) (*easypay.PaymentURLResponse, error) {
+	if err := f.begin(ctx, MethodPaymentURL, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.newOrder(request)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodPaymentURL, request, apiErr.err())
+	}
+
+	link, err := url.Parse(fakeURL + "/pay/" + order.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := order.response()
+	response.ForwardUrl = link.String()
+	f.recordLocked(MethodPaymentURL, request, response, nil)
+
+	return &easypay.PaymentURLResponse{
+		URL:           link,
+		TransactionID: response.TransactionId,
+		OrderID:       response.OrderId,
+		PaymentState:  response.PaymentState,
+		Response:      response,
+	}, nil
+}
+
+func (f *Fake) Payment(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.PaymentCtx(context.Background(), request)
+}
+
+func (f *Fake) PaymentCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	return f.authorize(ctx, MethodPayment, request, false)
+}
+
+func (f *Fake) Hold(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.HoldCtx(context.Background(), request)
+}
+
+func (f *Fake) CreateRecurrent(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.CreateRecurrentCtx(context.Background(), request)
+}
+
+// CreateRecurrentCtx charges the first payment of the schedule, the later charges are not made by the Fake
+func (f *Fake) CreateRecurrentCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	return f.authorize(ctx, MethodCreateRecurrent, request, false)
+}
+
+func (f *Fake) HoldCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	return f.authorize(ctx, MethodHold, request, true)
+}
+
+func (f *Fake) authorize(ctx context.Context, method Method, request *go_easypay.Request, hold bool) (*easypay.Response, error) {
+	if err := f.begin(ctx, method, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.newOrder(request)
+	if apiErr != nil {
+		return nil, f.failLocked(method, request, apiErr.err())
+	}
+
+	order.authorize(hold)
+
+	response := order.response()
+	f.recordLocked(method, request, response, nil)
+
+	return response, nil
+}
+
+func (f *Fake) Capture(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.CaptureCtx(context.Background(), request)
+}
+
+func (f *Fake) CaptureCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	if err := f.begin(ctx, MethodCapture, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.find(request)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodCapture, request, apiErr.err())
+	}
+
+	amount := request.GetAmount()
+	if !amount.SameCurrency(order.Amount) {
+		err := fmt.Errorf("%w: capture in %s of order in %s", go_easypay.ErrCurrencyMismatch, amount.Currency(), order.Amount.Currency())
+		return nil, f.failLocked(MethodCapture, request, err)
+	}
+
+	// like the client, a zero amount is not sent and captures the whole hold
+	var sent *currency.Money
+	if !amount.IsZero() {
+		sent = &amount
+	}
+
+	captured, apiErr := order.capture(sent)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodCapture, request, apiErr.err())
+	}
+
+	response := order.response()
+	response.Amount = &captured
+	f.recordLocked(MethodCapture, request, response, nil)
+
+	return response, nil
+}
+
+func (f *Fake) Refund(request *go_easypay.Request) (*easypay.CancelPaymentResponse, error) {
+	return f.RefundCtx(context.Background(), request)
+}
+
+func (f *Fake) RefundCtx(ctx context.Context, request *go_easypay.Request) (*easypay.CancelPaymentResponse, error) {
+	if err := f.begin(ctx, MethodRefund, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.find(request)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodRefund, request, apiErr.err())
+	}
+
+	if order.State == easypay.PaymentHold {
+		err := fmt.Errorf("%w: order %s", go_easypay.ErrOrderIsHeld, order.OrderID)
+		return nil, f.failLocked(MethodRefund, request, err)
+	}
+
+	ledger, err := order.state().RefundLedger()
+	if err != nil {
+		return nil, f.failLocked(MethodRefund, request, err)
+	}
+
+	remaining, err := ledger.Remaining()
+	if err != nil {
+		return nil, f.failLocked(MethodRefund, request, err)
+	}
+
+	amount := request.GetAmount()
+	if amount.IsZero() {
+		amount = remaining
+	}
+
+	if !amount.SameCurrency(order.Amount) {
+		err := fmt.Errorf("%w: refund in %s of order in %s", go_easypay.ErrCurrencyMismatch, amount.Currency(), order.Amount.Currency())
+		return nil, f.failLocked(MethodRefund, request, err)
+	}
+
+	// the currency was checked above, the amounts can be compared
+	if amount.IsZero() || amount.Cmp(remaining) > 0 {
+		err := fmt.Errorf("%w: requested %s, refundable %s", go_easypay.ErrRefundExceedsRemaining, amount, remaining)
+		return nil, f.failLocked(MethodRefund, request, err)
+	}
+
+	refund, apiErr := order.cancel(&amount, f.nextID)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodRefund, request, apiErr.err())
+	}
+
+	response := easypay.NewCancelPaymentResponse(order.cancelResponse(refund))
+	ledger.Record(response.RefundTransactionID, amount)
+	response.Ledger = ledger
+	f.recordLocked(MethodRefund, request, response, nil)
+
+	return response, nil
+}
+
+func (f *Fake) VoidHold(request *go_easypay.Request) (*easypay.VoidHoldResponse, error) {
+	return f.VoidHoldCtx(context.Background(), request)
+}
+
+func (f *Fake) VoidHoldCtx(ctx context.Context, request *go_easypay.Request) (*easypay.VoidHoldResponse, error) {
+	if err := f.begin(ctx, MethodVoidHold, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, apiErr := f.find(request)
+	if apiErr != nil {
+		return nil, f.failLocked(MethodVoidHold, request, apiErr.err())
+	}
+
+	if order.State != easypay.PaymentHold {
+		err := fmt.Errorf("%w: order %s is %s", go_easypay.ErrOrderIsNotHeld, order.OrderID, order.State)
+		return nil, f.failLocked(MethodVoidHold, request, err)
+	}
+
+	released := order.Amount
+	if _, apiErr = order.cancel(nil, f.nextID); apiErr != nil {
+		return nil, f.failLocked(MethodVoidHold, request, apiErr.err())
+	}
+
+	response := easypay.NewVoidHoldResponse(order.cancelResponse(nil), released)
+	f.recordLocked(MethodVoidHold, request, response, nil)
+
+	return response, nil
+}
+
+func (f *Fake) Credit(request *go_easypay.Request) (*easypay.Response, error) {
+	return f.CreditCtx(context.Background(), request)
+}
+
+// CreditCtx fails like the client, payouts are not supported until the Easypay payout contract is confirmed
+func (f *Fake) CreditCtx(ctx context.Context, request *go_easypay.Request) (*easypay.Response, error) {
+	if err := f.begin(ctx, MethodCredit, request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return nil, f.failLocked(MethodCredit, request, go_easypay.ErrCreditNotSupported)
+}
+
+func (f *Fake) GetRecordedExchange(_ context.Context, requestID string) (*easypay.RecordedExchange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, exchange := range f.exchanges {
+		if exchange.RequestID == requestID {
+			return exchange, nil
+		}
+	}
+
+	return nil, fmt.Errorf("exchange %s not found", requestID)
+}
+
+func (f *Fake) GetExchangesByOrderID(_ context.Context, orderID string) ([]*easypay.RecordedExchange, error) {
+	return f.exchangesByTag("order_id", orderID), nil
+}
+
+func (f *Fake) GetExchangesByTransactionID(_ context.Context, transactionID string) ([]*easypay.RecordedExchange, error) {
+	return f.exchangesByTag("transaction_id", transactionID), nil
+}
+
+func (f *Fake) exchangesByTag(tag string, value string) []*easypay.RecordedExchange {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var exchanges []*easypay.RecordedExchange
+	for _, exchange := range f.exchanges {
+		if exchange.Tags[tag] == value {
+			exchanges = append(exchanges, exchange)
+		}
+	}
+
+	return exchanges
+}
+
+// begin validates the request as the client does and applies injected failures, it is called without the lock
+func (f *Fake) begin(ctx context.Context, method Method, request *go_easypay.Request) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := request.Validate(operations[method]); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	hook := f.hook
+	var injected error
+	if queued := f.failures[method]; len(queued) > 0 {
+		injected = queued[0]
+		f.failures[method] = queued[1:]
+	}
+	f.mu.Unlock()
+
+	if injected == nil && hook != nil {
+		injected = hook(method, request)
+	}
+
+	if injected != nil {
+		return f.fail(method, request, injected)
+	}
+
+	return nil
+}
+
+func (f *Fake) fail(method Method, request *go_easypay.Request, err error) error {
+	f.record(method, request, nil, err)
+
+	return err
+}
+
+func (f *Fake) failLocked(method Method, request *go_easypay.Request, err error) error {
+	f.recordLocked(method, request, nil, err)
+
+	return err
+}
+
+func (f *Fake) record(method Method, request *go_easypay.Request, response any, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.recordLocked(method, request, response, err)
+}
+
+// recordLocked keeps the exchange for the recorder lookups, secrets of the merchant are not recorded
+func (f *Fake) recordLocked(method Method, request *go_easypay.Request, response any, err error) {
+	exchange := &easypay.RecordedExchange{
+		RequestID: uuid.New().String(),
+		Error:     err,
+		Tags:      map[string]string{"method": string(method)},
+		Timestamp: time.Now(),
+	}
+
+	exchange.Request, _ = json.Marshal(request.PaymentData)
+	if response != nil {
+		exchange.Response, _ = json.Marshal(response)
+	}
+
+	if paymentID := request.GetPaymentID(); paymentID != nil {
+		exchange.Tags["order_id"] = *paymentID
+		if order, ok := f.orders[*paymentID]; ok {
+			exchange.Tags["transaction_id"] = strconv.FormatInt(order.TransactionID, 10)
+		}
+	} else if transactionID := request.GetTransactionID(); transactionID != nil {
+		exchange.Tags["transaction_id"] = strconv.FormatInt(*transactionID, 10)
+	}
+
+	f.exchanges = append(f.exchanges, exchange)
+}
+
+// find returns the order addressed by the PaymentID or EasypayPaymentID of the request
+func (f *Fake) find(request *go_easypay.Request) (*Order, *apiError) {
+	if paymentID := request.GetPaymentID(); paymentID != nil {
+		if order, ok := f.orders[*paymentID]; ok {
+			return order, nil
+		}
+	}
+
+	if transactionID := request.GetTransactionID(); transactionID != nil {
+		if order, ok := f.transactions[*transactionID]; ok {
+			return order, nil
+		}
+	}
+
+	return nil, newAPIError(easypay.CodeOrderNotFound, "order not found")
+}
+
+func (f *Fake) checkNewOrder(request *go_easypay.Request) *apiError {
+	if request.GetPaymentID() == nil || *request.GetPaymentID() == "" {
+		return fieldError("order.orderId", "order ID is required")
+	}
+
+	amount := request.GetAmount()
+	if amount.IsZero() || amount.IsNegative() {
+		return newAPIError(easypay.CodeInvalidAmount, "amount must be positive")
+	}
+
+	return nil
+}
+
+// newOrder creates a pending order for the request
+func (f *Fake) newOrder(request *go_easypay.Request) (*Order, *apiError) {
+	if apiErr := f.checkNewOrder(request); apiErr != nil {
+		return nil, apiErr
+	}
+
+	paymentID := *request.GetPaymentID()
+	if _, exists := f.orders[paymentID]; exists {
+		return nil, newAPIError(easypay.CodeDuplicateOrder, "order "+paymentID+" already exists")
+	}
+
+	amount := request.GetAmount()
+	order := &Order{
+		OrderID:       paymentID,
+		TransactionID: f.nextID(),
+		PartnerKey:    request.Merchant.PartnerKey,
+		ServiceKey:    request.Merchant.GetServiceKey(),
+		Description:   request.GetDescription(),
+		Amount:        amount,
+		Captured:      currency.New(0, amount.Currency()),
+		State:         easypay.StatusPending,
+		CardGuid:      deref(request.GetCardToken()),
+	}
+	if webhookURL := request.GetWebhookURL(); webhookURL != nil {
+		order.WebhookURL = *webhookURL
+	}
+
+	f.orders[order.OrderID] = order
+	f.transactions[order.TransactionID] = order
+
+	return order, nil
+}
+
+func (f *Fake) nextID() int64 {
+	f.lastID++
+
+	return f.lastID
+}
@@ -0,0 +1,246 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/easypaytest"
+)
+
+var fakeMerchant = &go_easypay.Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret"}
+
+func build(t *testing.T, builder *go_easypay.RequestBuilder) *go_easypay.Request {
+	t.Helper()
+
+	request, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	return request
+}
+
+func pay(t *testing.T, fake *easypaytest.Fake, orderID string) {
+	t.Helper()
+
+	request := build(t, go_easypay.NewPayment(fakeMerchant).Order(orderID).Amount(currency.New(1000, currency.UAH)).Card("token"))
+	if _, err := fake.Payment(request); err != nil {
+		t.Fatalf("Payment error = %v", err)
+	}
+}
+
+func errorCode(err error) string {
+	apiError, ok := easypay.AsAPIError(err)
+	if !ok || apiError.Resp.Error.ErrorCode == nil {
+		return ""
+	}
+
+	return *apiError.Resp.Error.ErrorCode
+}
+
+func TestFakeCaptureOfOrderNotHeld(t *testing.T) {
+	fake := easypaytest.NewFake()
+	pay(t, fake, "order-1")
+
+	_, err := fake.Capture(build(t, go_easypay.NewCapture(fakeMerchant).Order("order-1")))
+	if code := errorCode(err); code != "INVALID_ORDER_STATE" {
+		t.Fatalf("Capture error = %v, want INVALID_ORDER_STATE", err)
+	}
+
+	order, _ := fake.Order("order-1")
+	if order.State != easypay.StatusConfirmed || order.Captured.MinorUnits() != 1000 {
+		t.Errorf("order is %s, captured %s, want the payment unchanged", order.State, order.Captured)
+	}
+}
+
+func TestFakeRefundAboveRemaining(t *testing.T) {
+	fake := easypaytest.NewFake()
+	pay(t, fake, "order-1")
+
+	refund := func(amount currency.Money) (*easypay.CancelPaymentResponse, error) {
+		return fake.Refund(build(t, go_easypay.NewRefund(fakeMerchant).Order("order-1").Amount(amount)))
+	}
+
+	if _, err := refund(currency.New(400, currency.UAH)); err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+
+	if _, err := refund(currency.New(700, currency.UAH)); !errors.Is(err, go_easypay.ErrRefundExceedsRemaining) {
+		t.Fatalf("Refund error = %v, want ErrRefundExceedsRemaining", err)
+	}
+
+	order, _ := fake.Order("order-1")
+	if order.Refunded().MinorUnits() != 400 || order.State != easypay.StatusConfirmed {
+		t.Fatalf("order is %s, refunded %s, want the rejected refund not applied", order.State, order.Refunded())
+	}
+
+	// a refund without an amount refunds what is left
+	response, err := fake.Refund(build(t, go_easypay.NewRefund(fakeMerchant).Order("order-1")))
+	if err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+	if remaining, err := response.Ledger.Remaining(); err != nil || !remaining.IsZero() {
+		t.Errorf("remaining refundable amount = %s, %v, want 0.00", remaining, err)
+	}
+
+	order, _ = fake.Order("order-1")
+	if order.Refunded().MinorUnits() != 1000 || order.State != easypay.StatusRefunded {
+		t.Errorf("order is %s, refunded %s, want it fully refunded", order.State, order.Refunded())
+	}
+
+	if _, err = refund(currency.New(100, currency.UAH)); !errors.Is(err, go_easypay.ErrRefundExceedsRemaining) {
+		t.Errorf("Refund of a refunded order error = %v, want ErrRefundExceedsRemaining", err)
+	}
+}
+
+func TestFakeRejectsOtherCurrency(t *testing.T) {
+	fake := easypaytest.NewFake()
+	pay(t, fake, "order-1")
+
+	refund := build(t, go_easypay.NewRefund(fakeMerchant).Order("order-1").Amount(currency.New(400, currency.USD)))
+	if _, err := fake.Refund(refund); !errors.Is(err, go_easypay.ErrCurrencyMismatch) {
+		t.Errorf("Refund in USD error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	hold := build(t, go_easypay.NewHold(fakeMerchant).Order("order-2").Amount(currency.New(1000, currency.UAH)).Card("token"))
+	if _, err := fake.Hold(hold); err != nil {
+		t.Fatalf("Hold error = %v", err)
+	}
+
+	capture := build(t, go_easypay.NewCapture(fakeMerchant).Order("order-2").Amount(currency.New(400, currency.USD)))
+	if _, err := fake.Capture(capture); !errors.Is(err, go_easypay.ErrCurrencyMismatch) {
+		t.Errorf("Capture in USD error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	if order, _ := fake.Order("order-1"); !order.Refunded().IsZero() {
+		t.Errorf("order-1 refunded %s, want the refund rejected", order.Refunded())
+	}
+	if order, _ := fake.Order("order-2"); order.State != easypay.PaymentHold {
+		t.Errorf("order-2 is %s, want it still held", order.State)
+	}
+}
+
+func TestFakeVoidOfCapturedOrder(t *testing.T) {
+	fake := easypaytest.NewFake()
+	hold(t, fake, fakeMerchant, "order-1")
+
+	if _, err := fake.Capture(build(t, go_easypay.NewCapture(fakeMerchant).Order("order-1"))); err != nil {
+		t.Fatalf("Capture error = %v", err)
+	}
+
+	_, err := fake.VoidHold(build(t, go_easypay.NewVoidHold(fakeMerchant).Order("order-1")))
+	if !errors.Is(err, go_easypay.ErrOrderIsNotHeld) {
+		t.Fatalf("VoidHold error = %v, want ErrOrderIsNotHeld", err)
+	}
+
+	order, _ := fake.Order("order-1")
+	if order.State != easypay.StatusConfirmed || order.Captured.MinorUnits() != 1000 {
+		t.Errorf("order is %s, captured %s, want the capture kept", order.State, order.Captured)
+	}
+}
+
+func TestFakeFailNext(t *testing.T) {
+	fake := easypaytest.NewFake()
+
+	first := errors.New("first failure")
+	second := errors.New("second failure")
+	fake.FailNext(easypaytest.MethodPayment, first)
+	fake.FailNext(easypaytest.MethodPayment, second)
+
+	// failures are queued per method
+	hold(t, fake, fakeMerchant, "order-hold")
+
+	request := build(t, go_easypay.NewPayment(fakeMerchant).Order("order-1").Amount(currency.New(1000, currency.UAH)).Card("token"))
+
+	for _, want := range []error{first, second} {
+		if _, err := fake.Payment(request); !errors.Is(err, want) {
+			t.Fatalf("Payment error = %v, want %v", err, want)
+		}
+
+		if _, ok := fake.Order("order-1"); ok {
+			t.Fatal("a failed payment created the order")
+		}
+	}
+
+	if _, err := fake.Payment(request); err != nil {
+		t.Fatalf("Payment error = %v, want the queue drained", err)
+	}
+
+	exchanges, err := fake.GetExchangesByOrderID(context.Background(), "order-1")
+	if err != nil {
+		t.Fatalf("GetExchangesByOrderID error = %v", err)
+	}
+	if len(exchanges) != 3 || !errors.Is(exchanges[0].Error, first) || exchanges[2].Error != nil {
+		t.Errorf("recorded %d exchanges, want both failures and the payment", len(exchanges))
+	}
+}
+
+func TestFakeOrderLookups(t *testing.T) {
+	fake := easypaytest.NewFake()
+	pay(t, fake, "order-1")
+
+	if _, ok := fake.Order("missing"); ok {
+		t.Error("Order returned an order that was never created")
+	}
+
+	order, ok := fake.Order("order-1")
+	if !ok {
+		t.Fatal("order-1 was not created")
+	}
+
+	// the order is addressed by the Easypay payment ID as well
+	state, err := fake.OrderState(build(t, go_easypay.NewStatus(fakeMerchant).EasypayPaymentID(order.TransactionID)))
+	if err != nil {
+		t.Fatalf("OrderState error = %v", err)
+	}
+	if state.OrderID != "order-1" || state.PaymentState != easypay.StatusConfirmed {
+		t.Errorf("OrderState = %s %s, want order-1 Confirmed", state.OrderID, state.PaymentState)
+	}
+
+	_, err = fake.OrderState(build(t, go_easypay.NewStatus(fakeMerchant).Order("missing")))
+	if !errors.Is(err, easypay.ErrOrderNotFound) {
+		t.Errorf("OrderState of a missing order error = %v, want ErrOrderNotFound", err)
+	}
+
+	// Order returns a copy, changing it does not change the fake
+	if _, err = fake.Refund(build(t, go_easypay.NewRefund(fakeMerchant).Order("order-1").Amount(currency.New(100, currency.UAH)))); err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+
+	snapshot, _ := fake.Order("order-1")
+	snapshot.State = easypay.StatusRejected
+	snapshot.Refunds[0].Amount = currency.New(900, currency.UAH)
+
+	order, _ = fake.Order("order-1")
+	if order.State != easypay.StatusConfirmed || order.Refunded().MinorUnits() != 100 {
+		t.Errorf("order is %s, refunded %s, want it unchanged by the copy", order.State, order.Refunded())
+	}
+}
@@ -0,0 +1,491 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/google/uuid"
+
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// Order is the state of an order kept by the Server
+type Order struct {
+	OrderID       string
+	TransactionID int64
+	PartnerKey    string
+	ServiceKey    string
+	Description   string
+	Amount        currency.Money
+	Captured      currency.Money
+	State         easypay.Status
+	CardGuid      string
+	WebhookURL    string
+	Refunds       []Refund
+	// OneTimePay, SuccessURL and FailURL are the payment page settings of an order paid by the customer
+	OneTimePay bool
+	SuccessURL string
+	FailURL    string
+	PayeeID    string
+	PayeeName  string
+	Narrative  string
+}
+
+// Refund is a refund transaction made for an order
+type Refund struct {
+	TransactionID int64
+	Amount        currency.Money
+}
+
+// Refunded returns the sum of all refunds of the order
+func (o *Order) Refunded() currency.Money {
+	refunded := currency.New(0, o.Amount.Currency())
+	for _, refund := range o.Refunds {
+		refunded = refunded.Add(refund.Amount)
+	}
+
+	return refunded
+}
+
+// Order returns a copy of the order with the given merchant order ID
+func (s *Server) Order(orderID string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return Order{}, false
+	}
+
+	snapshot := *order
+	snapshot.Refunds = append([]Refund(nil), order.Refunds...)
+
+	return snapshot, true
+}
+
+// Pay completes an order created without a payment instrument, as if the customer paid on the payment page
+func (s *Server) Pay(orderID string) error {
+	s.mu.Lock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", easypay.ErrOrderNotFound, orderID)
+	}
+
+	if order.State != easypay.StatusPending {
+		s.mu.Unlock()
+		return fmt.Errorf("order %s is %s, only pending orders can be paid", orderID, order.State)
+	}
+
+	notifications := s.authorize(order, false)
+	s.mu.Unlock()
+
+	for _, n := range notifications {
+		s.deliver(n)
+	}
+
+	return nil
+}
+
+func (s *Server) createOrder(c *call) (any, []notification, *apiError) {
+	order := c.body.Order
+	if order == nil || order.OrderID == nil || *order.OrderID == "" {
+		return nil, nil, fieldError("order.orderId", "order ID is required")
+	}
+
+	if order.Amount == nil || order.Amount.IsZero() || order.Amount.IsNegative() {
+		return nil, nil, newAPIError(easypay.CodeInvalidAmount, "amount must be positive")
+	}
+
+	if _, exists := s.orders[*order.OrderID]; exists {
+		return nil, nil, newAPIError(easypay.CodeDuplicateOrder, "order "+*order.OrderID+" already exists")
+	}
+
+	if c.body.Recurrent != nil {
+		if apiErr := checkRecurrent(c.body); apiErr != nil {
+			return nil, nil, apiErr
+		}
+	}
+
+	amount := *order.Amount
+	if order.Currency != nil {
+		amount = amount.WithCurrency(currency.Code(*order.Currency))
+	}
+
+	o := &Order{
+		OrderID:       *order.OrderID,
+		TransactionID: s.nextID(),
+		PartnerKey:    c.partnerKey,
+		ServiceKey:    deref(order.ServiceKey),
+		Description:   deref(order.Description),
+		Amount:        amount,
+		Captured:      currency.New(0, amount.Currency()),
+		State:         easypay.StatusPending,
+		WebhookURL:    notifyURL(c.body),
+		OneTimePay:    order.IsOneTimePay != nil && *order.IsOneTimePay,
+	}
+
+	if c.body.URLs != nil {
+		o.SuccessURL = deref(c.body.URLs.Success)
+		o.FailURL = deref(c.body.URLs.Failed)
+	}
+
+	o.PayeeID, o.PayeeName, o.Narrative = payee(c.body.BankingDetails)
+
+	s.orders[o.OrderID] = o
+	s.transactions[o.TransactionID] = o
+
+	instrument := c.body.UserPaymentInstrument
+	if instrument == nil || (instrument.CardGuid == nil && instrument.Token == nil) {
+		// the customer pays on the payment page, see Pay
+		response := o.response()
+		response.ForwardUrl = s.URL + "/pay/" + o.OrderID
+
+		return response, nil, nil
+	}
+
+	o.CardGuid = deref(instrument.CardGuid)
+	hold := order.PaymentOperation != nil && *order.PaymentOperation == string(consts.PaymentOperationPaymentHold)
+
+	notifications := s.authorize(o, hold)
+
+	if c.body.Recurrent != nil {
+		s.createRecurrent(o, c.body)
+	}
+
+	return o.response(), notifications, nil
+}
+
+func (s *Server) authorize(o *Order, hold bool) []notification {
+	o.authorize(hold)
+
+	if hold {
+		return []notification{s.orderNotification(o, "hold", o.Amount)}
+	}
+
+	return []notification{s.orderNotification(o, "payment", o.Amount)}
+}
+
+func (s *Server) unHoldOrder(c *call) (any, []notification, *apiError) {
+	o, apiErr := s.lookup(c.body)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	amount, apiErr := o.capture(c.body.Amount)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	response := o.response()
+	response.Amount = &amount
+
+	return response, []notification{s.orderNotification(o, "payment", amount)}, nil
+}
+
+func (s *Server) cancelOrder(c *call) (any, []notification, *apiError) {
+	o, apiErr := s.lookup(c.body)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	refund, apiErr := o.cancel(c.body.Amount, s.nextID)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	response := o.cancelResponse(refund)
+
+	return response, []notification{s.orderNotification(o, "refund", *response.Amount)}, nil
+}
+
+func (s *Server) orderState(c *call) (any, []notification, *apiError) {
+	o, apiErr := s.lookup(c.body)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	return o.state(), nil, nil
+}
+
+// lookup finds the order addressed by the root order ID or transaction ID of the request
+func (s *Server) lookup(body *easypay.Request) (*Order, *apiError) {
+	if body.OrderID != nil {
+		if o, ok := s.orders[*body.OrderID]; ok {
+			return o, nil
+		}
+	}
+
+	if body.TransactionID != nil {
+		if o, ok := s.transactions[*body.TransactionID]; ok {
+			return o, nil
+		}
+	}
+
+	return nil, newAPIError(easypay.CodeOrderNotFound, "order not found")
+}
+
+func (o *Order) response() *easypay.Response {
+	transactionID := o.TransactionID
+	orderID := o.OrderID
+	amount := o.Amount
+
+	return &easypay.Response{
+		PaymentState:  o.State,
+		TransactionId: &transactionID,
+		OrderId:       &orderID,
+		MerchantKey:   &o.ServiceKey,
+		Amount:        &amount,
+	}
+}
+
+// capture charges the held amount, the whole hold when amount is nil. An amount that is sent must be positive.
+func (o *Order) capture(amount *currency.Money) (currency.Money, *apiError) {
+	if o.State != easypay.PaymentHold {
+		return currency.Money{}, newAPIError("INVALID_ORDER_STATE", fmt.Sprintf("order is %s, only held orders can be captured", o.State))
+	}
+
+	captured := o.Amount
+	if amount != nil {
+		captured = amount.WithCurrency(o.Amount.Currency())
+	}
+
+	if captured.IsZero() || captured.IsNegative() || captured.Cmp(o.Amount) > 0 {
+		return currency.Money{}, newAPIError(easypay.CodeInvalidAmount, "capture must be positive and within the held amount")
+	}
+
+	o.State = easypay.StatusConfirmed
+	o.Captured = captured
+
+	return captured, nil
+}
+
+// cancel releases a hold or refunds a captured order, the whole remaining amount when amount is nil.
+// The refund is nil when a hold was released.
+func (o *Order) cancel(amount *currency.Money, nextID func() int64) (*Refund, *apiError) {
+	switch o.State {
+	case easypay.PaymentHold:
+		// cancelling a hold releases the whole amount, nothing was charged
+		o.State = easypay.StatusRefunded
+
+		return nil, nil
+	case easypay.StatusConfirmed:
+	default:
+		return nil, newAPIError("INVALID_ORDER_STATE", fmt.Sprintf("order is %s, it cannot be cancelled", o.State))
+	}
+
+	remaining := o.Captured.Sub(o.Refunded())
+
+	refunded := remaining
+	if amount != nil {
+		refunded = amount.WithCurrency(o.Amount.Currency())
+	}
+
+	if refunded.IsZero() || refunded.IsNegative() || refunded.Cmp(remaining) > 0 {
+		return nil, newAPIError(easypay.CodeInvalidAmount, fmt.Sprintf("refund must be positive and within the refundable %s", remaining))
+	}
+
+	refund := Refund{TransactionID: nextID(), Amount: refunded}
+	o.Refunds = append(o.Refunds, refund)
+
+	if o.Refunded().Cmp(o.Captured) == 0 {
+		o.State = easypay.StatusRefunded
+	}
+
+	return &refund, nil
+}
+
+func (o *Order) cancelResponse(refund *Refund) *easypay.Response {
+	response := o.response()
+	response.PaymentState = easypay.StatusCancelingAccepted
+
+	if refund != nil {
+		amount := refund.Amount
+		refundID := int(refund.TransactionID)
+		response.Amount = &amount
+		response.RefundTransactionId = &refundID
+	}
+
+	return response
+}
+
+func (o *Order) state() *easypay.PaymentStatusResponse {
+	state := &easypay.PaymentStatusResponse{
+		MerchantKey:   o.ServiceKey,
+		TransactionID: o.TransactionID,
+		OrderID:       o.OrderID,
+		Amount:        o.Amount,
+		PaymentState:  o.State,
+	}
+
+	if !o.Captured.IsZero() {
+		state.PaymentsList = append(
+			state.PaymentsList, easypay.PaymentDetail{
+				MerchantKey:   o.ServiceKey,
+				TransactionID: o.TransactionID,
+				OrderID:       o.OrderID,
+				Amount:        o.Captured,
+				PaymentState:  easypay.StatusConfirmed,
+			},
+		)
+	}
+
+	for _, refund := range o.Refunds {
+		state.PaymentsList = append(
+			state.PaymentsList, easypay.PaymentDetail{
+				MerchantKey:         o.ServiceKey,
+				TransactionID:       o.TransactionID,
+				OrderID:             o.OrderID,
+				Amount:              refund.Amount,
+				PaymentState:        easypay.StatusRefunded,
+				RefundTransactionID: refund.TransactionID,
+			},
+		)
+	}
+
+	return state
+}
+
+// authorize charges or holds the order amount
+func (o *Order) authorize(hold bool) {
+	if hold {
+		o.State = easypay.PaymentHold
+		return
+	}
+
+	o.State = easypay.StatusConfirmed
+	o.Captured = o.Amount
+}
+
+// verification is a card verification link waiting for the customer
+type verification struct {
+	partnerKey string
+	phone      string
+	webhookURL string
+}
+
+func (s *Server) createCardToken(c *call) (any, []notification, *apiError) {
+	if c.body.Phone == nil || *c.body.Phone == "" {
+		return nil, nil, fieldError("phone", "phone is required")
+	}
+
+	id := uuid.New().String()
+	s.verifications[id] = &verification{
+		partnerKey: c.partnerKey,
+		phone:      *c.body.Phone,
+		webhookURL: notifyURL(c.body),
+	}
+
+	return &easypay.Response{ForwardUrl: s.URL + "/verify/" + id}, nil, nil
+}
+
+// CompleteVerification tokenizes the card as if the customer finished the verification link,
+// the card token is returned and sent in a notification
+func (s *Server) CompleteVerification(link string, pan string) (string, error) {
+	s.mu.Lock()
+
+	id := path.Base(link)
+	v, ok := s.verifications[id]
+	if !ok {
+		s.mu.Unlock()
+		return "", fmt.Errorf("unknown verification link %s", link)
+	}
+
+	delete(s.verifications, id)
+
+	cardGuid := uuid.New().String()
+	n := notification{
+		url:       s.webhookTarget(v.webhookURL),
+		secretKey: s.merchants[v.partnerKey],
+		webhook: &easypay.Webhook{
+			PartnerKey: v.partnerKey,
+			Phone:      v.phone,
+			CardGuid:   cardGuid,
+			Pan:        maskPan(pan),
+		},
+	}
+	s.mu.Unlock()
+
+	s.deliver(n)
+
+	return cardGuid, nil
+}
+
+func notifyURL(body *easypay.Request) string {
+	if body.URLs != nil && body.URLs.Notify != nil {
+		return *body.URLs.Notify
+	}
+
+	if body.Order != nil && body.Order.AdditionalItems != nil {
+		return (*body.Order.AdditionalItems)["Merchant.UrlNotify"]
+	}
+
+	return ""
+}
+
+// payee returns the payee and the narrative of the banking details
+func payee(details *easypay.BankingDetails) (id string, name string, narrative string) {
+	if details == nil {
+		return "", "", ""
+	}
+
+	if details.Payee != nil {
+		id, name = details.Payee.ID, details.Payee.Name
+	}
+
+	if details.Narrative != nil {
+		narrative = details.Narrative.Name
+	}
+
+	return id, name, narrative
+}
+
+func maskPan(pan string) string {
+	if len(pan) < 10 {
+		return pan
+	}
+
+	return pan[:6] + "******" + pan[len(pan)-4:]
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func fieldError(field string, message string) *apiError {
+	apiErr := newAPIError("VALIDATION_ERROR", message)
+	apiErr.fields = []easypay.FieldError{{FieldName: field, ErrorMessage: message}}
+
+	return apiErr
+}
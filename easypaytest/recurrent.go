@@ -0,0 +1,92 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest
+
+import (
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// Recurrent is the schedule of a recurrent payment kept by the Server, it is created with the order of its first charge
+type Recurrent struct {
+	OrderID     string
+	PartnerKey  string
+	CronRule    string
+	DateRun     string
+	DateExpire  string
+	FailedCount int
+	FailedRule  string
+	Amount      currency.Money
+	NotifyURL   string
+}
+
+// Recurrent returns a copy of the recurrent payment created with the given merchant order ID
+func (s *Server) Recurrent(orderID string) (Recurrent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recurrent, ok := s.recurrents[orderID]
+	if !ok {
+		return Recurrent{}, false
+	}
+
+	return *recurrent, true
+}
+
+// checkRecurrent validates the schedule sent with a createOrder request
+func checkRecurrent(body *easypay.Request) *apiError {
+	if err := easypay.CronRule(body.Recurrent.CronRule).Validate(); err != nil {
+		return fieldError("reccurent.cronRule", err.Error())
+	}
+
+	if body.Recurrent.DateExpire == "" {
+		return fieldError("reccurent.dateExpire", "expire date is required")
+	}
+
+	instrument := body.UserPaymentInstrument
+	if instrument == nil || instrument.CardGuid == nil || *instrument.CardGuid == "" {
+		return fieldError("userPaymentInstrument.cardGuid", "recurrent payments are charged from a card token")
+	}
+
+	return nil
+}
+
+// createRecurrent schedules the charges of the order, the caller holds the lock
+func (s *Server) createRecurrent(o *Order, body *easypay.Request) {
+	properties := body.Recurrent.Properties
+
+	r := &Recurrent{
+		OrderID:     o.OrderID,
+		PartnerKey:  o.PartnerKey,
+		CronRule:    body.Recurrent.CronRule,
+		DateRun:     body.Recurrent.DateRun,
+		DateExpire:  body.Recurrent.DateExpire,
+		FailedCount: properties.FailedCount,
+		FailedRule:  properties.FailedRule,
+		Amount:      properties.Amount.WithCurrency(o.Amount.Currency()),
+		NotifyURL:   properties.UrlNotify,
+	}
+	s.recurrents[r.OrderID] = r
+}
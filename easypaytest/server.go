@@ -0,0 +1,319 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package easypaytest provides fakes of Easypay for tests: Server is an in-process fake of the merchant API
+// for integration tests, Fake is an in-memory implementation of the Easypay interface for unit tests.
+package easypaytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/easypay"
+	easypayhttp "github.com/stremovskyy/go-easypay/internal/http"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+// Credentials of the merchant every Server accepts
+const (
+	DefaultPartnerKey = "easypaytest-partner"
+	DefaultServiceKey = "easypaytest-service"
+	DefaultSecretKey  = "easypaytest-secret"
+)
+
+// Server is a fake Easypay API listening on a local address
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	merchants     map[string]string
+	apps          map[string]string
+	pages         map[string]string
+	orders        map[string]*Order
+	transactions  map[int64]*Order
+	recurrents    map[string]*Recurrent
+	verifications map[string]*verification
+	failures      []*Failure
+	calls         map[string]int
+	lastID        int64
+	webhookURL    string
+	webhookClient *http.Client
+	deliveries    []Delivery
+}
+
+type Option func(*Server)
+
+// WithMerchant registers another partner key and the secret key its requests are signed with
+func WithMerchant(partnerKey string, secretKey string) Option {
+	return func(s *Server) {
+		s.merchants[partnerKey] = secretKey
+	}
+}
+
+// WithWebhookURL sends every notification to url instead of the notify URL of the order
+func WithWebhookURL(url string) Option {
+	return func(s *Server) {
+		s.webhookURL = url
+	}
+}
+
+// WithWebhookClient sets the HTTP client used to deliver notifications
+func WithWebhookClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.webhookClient = client
+	}
+}
+
+// NewServer starts a fake Easypay API, it is stopped with Close
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		merchants:     map[string]string{DefaultPartnerKey: DefaultSecretKey},
+		apps:          make(map[string]string),
+		pages:         make(map[string]string),
+		orders:        make(map[string]*Order),
+		transactions:  make(map[int64]*Order),
+		recurrents:    make(map[string]*Recurrent),
+		verifications: make(map[string]*verification),
+		calls:         make(map[string]int),
+		lastID:        100000,
+		webhookClient: http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
+
+// Merchant returns the default merchant accepted by the server
+func (s *Server) Merchant() *go_easypay.Merchant {
+	return &go_easypay.Merchant{
+		Name:       "easypaytest",
+		PartnerKey: DefaultPartnerKey,
+		ServiceKey: DefaultServiceKey,
+		SecretKey:  DefaultSecretKey,
+	}
+}
+
+// Client returns a client sending its requests to the server
+func (s *Server) Client(options ...go_easypay.Option) go_easypay.Easypay {
+	options = append([]go_easypay.Option{go_easypay.WithBaseURL(s.URL)}, options...)
+
+	return go_easypay.NewClient(options...)
+}
+
+// ExpireApps forgets every app and page, the next requests are rejected with APP_EXPIRED
+func (s *Server) ExpireApps() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps = make(map[string]string)
+	s.pages = make(map[string]string)
+}
+
+// Calls returns how many requests were made to the endpoint path, such as consts.CreateOrderPath
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[path]
+}
+
+// call is the decoded request passed to the endpoint handlers
+type call struct {
+	partnerKey string
+	secretKey  string
+	appID      string
+	body       *easypay.Request
+}
+
+type endpoint func(c *call) (any, []notification, *apiError)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	handler, sessionless := s.route(r.URL.Path)
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls[r.URL.Path]++
+	failure := s.nextFailure(r.URL.Path)
+	s.mu.Unlock()
+
+	if failure != nil && !failure.AfterProcessing {
+		failure.write(w)
+		return
+	}
+
+	response, notifications, apiErr := s.handle(r, raw, handler, sessionless)
+
+	for _, n := range notifications {
+		s.deliver(n)
+	}
+
+	if failure != nil {
+		failure.write(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if apiErr != nil {
+		writeJSON(w, apiErr.response())
+		return
+	}
+
+	writeJSON(w, response)
+}
+
+func (s *Server) route(path string) (handler endpoint, sessionless bool) {
+	switch path {
+	case consts.CreateAppPath:
+		return s.createApp, true
+	case consts.CreatePagePath:
+		return s.createPage, true
+	case consts.CreateOrderPath:
+		return s.createOrder, false
+	case consts.CancelOrderPath:
+		return s.cancelOrder, false
+	case consts.UnHoldPath:
+		return s.unHoldOrder, false
+	case consts.CheckOrderStatePath:
+		return s.orderState, false
+	case consts.CardTokenCreatePath:
+		return s.createCardToken, false
+	}
+
+	return nil, false
+}
+
+// handle authenticates the request and runs the endpoint under the server lock
+func (s *Server) handle(r *http.Request, raw []byte, handler endpoint, sessionless bool) (any, []notification, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partnerKey := r.Header.Get("PartnerKey")
+	secretKey, ok := s.merchants[partnerKey]
+	if !ok {
+		return nil, nil, newAPIError("PARTNER_NOT_FOUND", "unknown partner key")
+	}
+
+	if !sessionless {
+		appID := r.Header.Get("AppId")
+		if s.apps[appID] != partnerKey || s.pages[r.Header.Get("PageId")] != appID {
+			return nil, nil, newAPIError(easypay.CodeAppExpired, "app or page is not valid")
+		}
+
+		if r.Header.Get("Sign") != easypayhttp.ComputeSignature(secretKey, string(raw)) {
+			return nil, nil, newAPIError(easypay.CodeInvalidSignature, "request signature does not match")
+		}
+	}
+
+	body := &easypay.Request{}
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, body); err != nil {
+			return nil, nil, newAPIError("INVALID_REQUEST", err.Error())
+		}
+	}
+
+	return handler(&call{partnerKey: partnerKey, secretKey: secretKey, appID: r.Header.Get("AppId"), body: body})
+}
+
+func (s *Server) createApp(c *call) (any, []notification, *apiError) {
+	appID := uuid.New().String()
+	s.apps[appID] = c.partnerKey
+
+	return &easypay.Response{AppId: &appID}, nil, nil
+}
+
+func (s *Server) createPage(c *call) (any, []notification, *apiError) {
+	if s.apps[c.appID] != c.partnerKey {
+		return nil, nil, newAPIError(easypay.CodeAppExpired, "app is not valid")
+	}
+
+	pageID := uuid.New().String()
+	s.pages[pageID] = c.appID
+
+	return &easypay.Response{PageId: &pageID}, nil, nil
+}
+
+func (s *Server) nextID() int64 {
+	s.lastID++
+
+	return s.lastID
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// apiError is an Easypay error answered with status 200, as the API does for rejections
+type apiError struct {
+	code        string
+	description string
+	fields      []easypay.FieldError
+}
+
+func newAPIError(code string, description string) *apiError {
+	return &apiError{code: code, description: description}
+}
+
+func (e *apiError) response() *easypay.Response {
+	return &easypay.Response{
+		Error: &easypay.Error{
+			ErrorCode:   utils.Ref(e.code),
+			Title:       utils.Ref(strings.ReplaceAll(strings.ToLower(e.code), "_", " ")),
+			Description: utils.Ref(e.description),
+			FieldErrors: e.fields,
+		},
+	}
+}
+
+// err returns the error the client returns for the API error
+func (e *apiError) err() error {
+	return fmt.Errorf("easypay error: %w", &easypay.CustomError{Resp: e.response()})
+}
@@ -0,0 +1,454 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/easypaytest"
+	easypayhttp "github.com/stremovskyy/go-easypay/internal/http"
+)
+
+func hold(t *testing.T, client go_easypay.Easypay, merchant *go_easypay.Merchant, orderID string) {
+	t.Helper()
+
+	request, err := go_easypay.NewHold(merchant).Order(orderID).Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	if _, err = client.Hold(request); err != nil {
+		t.Fatalf("Hold error = %v", err)
+	}
+}
+
+func TestServerHoldCaptureRefund(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	merchant := server.Merchant()
+	client := server.Client()
+
+	hold(t, client, merchant, "order-1")
+
+	// the client creates a new app when the server rejects the cached one
+	server.ExpireApps()
+
+	capture, err := go_easypay.NewCapture(merchant).Order("order-1").Amount(currency.New(800, currency.UAH)).Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+	if _, err = client.Capture(capture); err != nil {
+		t.Fatalf("Capture error = %v", err)
+	}
+
+	if calls := server.Calls(consts.CreateAppPath); calls != 2 {
+		t.Errorf("createApp called %d times, want 2", calls)
+	}
+
+	refund, err := go_easypay.NewRefund(merchant).Order("order-1").Amount(currency.New(300, currency.UAH)).Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	refundResponse, err := client.Refund(refund)
+	if err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+	if remaining, err := refundResponse.Ledger.Remaining(); err != nil || remaining.MinorUnits() != 500 {
+		t.Errorf("remaining refundable amount = %s, %v, want 5.00", remaining, err)
+	}
+
+	order, ok := server.Order("order-1")
+	if !ok {
+		t.Fatal("order-1 is not on the server")
+	}
+	if order.State != easypay.StatusConfirmed || order.Captured.MinorUnits() != 800 || order.Refunded().MinorUnits() != 300 {
+		t.Errorf("order is %s, captured %s, refunded %s", order.State, order.Captured, order.Refunded())
+	}
+}
+
+func TestServerRejectsInvalidSignature(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	merchant := server.Merchant()
+	merchant.SecretKey = "wrong-secret"
+
+	request, err := go_easypay.NewPayment(merchant).Order("order-1").Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	if _, err = server.Client().Payment(request); !errors.Is(err, easypay.ErrInvalidSignature) {
+		t.Errorf("Payment error = %v, want ErrInvalidSignature", err)
+	}
+
+	if _, ok := server.Order("order-1"); ok {
+		t.Error("order with an invalid signature was created")
+	}
+}
+
+func TestServerScriptedFailures(t *testing.T) {
+	t.Run("retried status", func(t *testing.T) {
+		server := easypaytest.NewServer()
+		defer server.Close()
+
+		client := server.Client()
+		hold(t, client, server.Merchant(), "order-1")
+
+		server.Fail(easypaytest.Failure{Path: consts.CheckOrderStatePath, StatusCode: 503})
+
+		request, err := go_easypay.NewStatus(server.Merchant()).Order("order-1").Build()
+		if err != nil {
+			t.Fatalf("Build error = %v", err)
+		}
+
+		state, err := client.OrderState(request)
+		if err != nil {
+			t.Fatalf("OrderState error = %v", err)
+		}
+		if !state.IsHeld() {
+			t.Errorf("order is %s, want it held", state.PaymentState)
+		}
+		if calls := server.Calls(consts.CheckOrderStatePath); calls != 2 {
+			t.Errorf("orderState called %d times, want 2", calls)
+		}
+	})
+
+	t.Run("declined payment", func(t *testing.T) {
+		server := easypaytest.NewServer()
+		defer server.Close()
+
+		server.Fail(easypaytest.Failure{Path: consts.CreateOrderPath, ErrorCode: easypay.CodeCardDeclined})
+
+		request, err := go_easypay.NewPayment(server.Merchant()).Order("order-1").Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+		if err != nil {
+			t.Fatalf("Build error = %v", err)
+		}
+
+		if _, err = server.Client().Payment(request); !errors.Is(err, easypay.ErrCardDeclined) {
+			t.Errorf("Payment error = %v, want ErrCardDeclined", err)
+		}
+		if calls := server.Calls(consts.CreateOrderPath); calls != 1 {
+			t.Errorf("createOrder called %d times, a decline must not be retried", calls)
+		}
+	})
+
+	t.Run("lost payment response", func(t *testing.T) {
+		server := easypaytest.NewServer()
+		defer server.Close()
+
+		server.Fail(easypaytest.Failure{Path: consts.CreateOrderPath, Drop: true, AfterProcessing: true})
+
+		request, err := go_easypay.NewPayment(server.Merchant()).Order("order-1").Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+		if err != nil {
+			t.Fatalf("Build error = %v", err)
+		}
+
+		client := server.Client(go_easypay.WithIdempotencyStore(go_easypay.NewMemoryIdempotencyStore()))
+
+		response, err := client.Payment(request)
+		if err != nil {
+			t.Fatalf("Payment error = %v", err)
+		}
+		if response.PaymentState != easypay.StatusConfirmed {
+			t.Errorf("payment is %s, want %s", response.PaymentState, easypay.StatusConfirmed)
+		}
+		if calls := server.Calls(consts.CreateOrderPath); calls != 1 {
+			t.Errorf("createOrder called %d times, the payment must be resolved from the order state", calls)
+		}
+	})
+}
+
+func newCredit(merchant *go_easypay.Merchant, orderID string) *go_easypay.Request {
+	firstName, lastName, taxID, pan := "Taras", "Shevchenko", "1234567890", "4111111111111111"
+
+	return &go_easypay.Request{
+		Merchant:     merchant,
+		PersonalData: &go_easypay.PersonalData{FirstName: &firstName, LastName: &lastName, TaxID: &taxID},
+		PaymentData: &go_easypay.PaymentData{
+			PaymentID:   &orderID,
+			Amount:      currency.New(2550, currency.UAH),
+			Description: "salary",
+		},
+		PaymentMethod: &go_easypay.PaymentMethod{Card: &go_easypay.Card{Pan: &pan}},
+	}
+}
+
+// no payout is sent until the Easypay payout contract is confirmed
+func TestServerCreditIsNotSent(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	if _, err := server.Client().Credit(newCredit(server.Merchant(), "payout-1")); !errors.Is(err, go_easypay.ErrCreditNotSupported) {
+		t.Errorf("Credit error = %v, want ErrCreditNotSupported", err)
+	}
+
+	if calls := server.Calls(consts.CreateAppPath); calls != 0 {
+		t.Errorf("createApp called %d times, want no request", calls)
+	}
+}
+
+func newRecurrent(merchant *go_easypay.Merchant, orderID string, run time.Time) *go_easypay.Request {
+	token, webhookURL := "card-guid", "https://example.com/webhook"
+
+	return &go_easypay.Request{
+		Merchant: merchant,
+		PaymentData: &go_easypay.PaymentData{
+			PaymentID:   &orderID,
+			Amount:      currency.New(9900, currency.UAH),
+			Description: "subscription",
+			WebhookURL:  &webhookURL,
+		},
+		PaymentMethod: &go_easypay.PaymentMethod{Card: &go_easypay.Card{Token: &token}},
+		Recurrent: &go_easypay.Recurrent{
+			CronRule:    easypay.Monthly(1, 10, 0),
+			DateRun:     &run,
+			DateExpire:  run.AddDate(1, 0, 0),
+			FailedCount: 2,
+			FailedRule:  easypay.Daily(12, 0),
+		},
+	}
+}
+
+func TestServerRecurrent(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	run := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	created, err := server.Client().CreateRecurrent(newRecurrent(server.Merchant(), "subscription-1", run))
+	if err != nil {
+		t.Fatalf("CreateRecurrent error = %v", err)
+	}
+
+	if created.PaymentState != easypay.StatusConfirmed || created.OrderId == nil || *created.OrderId != "subscription-1" || created.TransactionId == nil {
+		t.Errorf("CreateRecurrent response = %+v, want the first charge of subscription-1 confirmed", created)
+	}
+
+	recurrent, ok := server.Recurrent("subscription-1")
+	if !ok {
+		t.Fatal("recurrent payment of subscription-1 is not on the server")
+	}
+
+	want := easypaytest.Recurrent{
+		OrderID:     "subscription-1",
+		PartnerKey:  easypaytest.DefaultPartnerKey,
+		CronRule:    "0 10 1 * *",
+		DateRun:     "2030-01-01T10:00:00",
+		DateExpire:  "2031-01-01T10:00:00",
+		FailedCount: 2,
+		FailedRule:  "0 12 * * *",
+		Amount:      currency.New(9900, currency.UAH),
+		NotifyURL:   "https://example.com/webhook",
+	}
+	if recurrent != want {
+		t.Errorf("recurrent payment = %+v, want %+v", recurrent, want)
+	}
+}
+
+func TestServerRecurrentRejectsInvalidSchedule(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	request := newRecurrent(server.Merchant(), "subscription-1", time.Now().Add(time.Hour))
+	request.PaymentMethod = nil
+
+	if _, err := server.Client().CreateRecurrent(request); len(easypay.FieldErrors(err)) == 0 {
+		t.Errorf("CreateRecurrent without a card token error = %v, want a field error", err)
+	}
+
+	if _, ok := server.Order("subscription-1"); ok {
+		t.Error("order of a rejected recurrent payment was created")
+	}
+}
+
+func TestServerPaymentURL(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	merchant := server.Merchant()
+	merchant.SuccessRedirect = "https://shop.example.com/success"
+	merchant.FailRedirect = "https://shop.example.com/fail"
+	merchant.PayeeID = "1234567890"
+	merchant.PayeeName = "Shop LLC"
+	merchant.PayeeNarative = "order payment"
+
+	orderID, webhookURL := "checkout-1", "https://shop.example.com/webhook"
+	request := &go_easypay.Request{
+		Merchant: merchant,
+		PaymentData: &go_easypay.PaymentData{
+			PaymentID:   &orderID,
+			Amount:      currency.New(12345, currency.UAH),
+			Description: "basket",
+			WebhookURL:  &webhookURL,
+		},
+	}
+
+	client := server.Client()
+
+	response, err := client.PaymentURL(request)
+	if err != nil {
+		t.Fatalf("PaymentURL error = %v", err)
+	}
+
+	if response.URL == nil || response.URL.String() != server.URL+"/pay/checkout-1" || response.URL.Path != "/pay/checkout-1" {
+		t.Errorf("PaymentURL URL = %v, want %s/pay/checkout-1", response.URL, server.URL)
+	}
+
+	order, ok := server.Order("checkout-1")
+	if !ok {
+		t.Fatal("checkout-1 is not on the server")
+	}
+
+	if response.TransactionID == nil || *response.TransactionID != order.TransactionID || response.OrderID == nil || *response.OrderID != "checkout-1" {
+		t.Errorf("PaymentURL response = %+v, want transaction %d of checkout-1", response, order.TransactionID)
+	}
+	if response.PaymentState != easypay.StatusPending {
+		t.Errorf("PaymentURL state = %s, want %s", response.PaymentState, easypay.StatusPending)
+	}
+
+	want := easypaytest.Order{
+		OrderID:       "checkout-1",
+		TransactionID: order.TransactionID,
+		PartnerKey:    easypaytest.DefaultPartnerKey,
+		ServiceKey:    easypaytest.DefaultServiceKey,
+		Description:   "basket",
+		Amount:        currency.New(12345, currency.UAH),
+		Captured:      currency.New(0, currency.UAH),
+		State:         easypay.StatusPending,
+		WebhookURL:    "https://shop.example.com/webhook",
+		OneTimePay:    true,
+		SuccessURL:    "https://shop.example.com/success",
+		FailURL:       "https://shop.example.com/fail",
+		PayeeID:       "1234567890",
+		PayeeName:     "Shop LLC",
+		Narrative:     "order payment",
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %+v, want %+v", order, want)
+	}
+
+	if err = server.Pay("checkout-1"); err != nil {
+		t.Fatalf("Pay error = %v", err)
+	}
+	if err = server.Pay("checkout-1"); err == nil {
+		t.Error("Pay of a paid order error = nil")
+	}
+
+	status, err := go_easypay.NewStatus(merchant).Order("checkout-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	state, err := client.Status(status)
+	if err != nil {
+		t.Fatalf("Status error = %v", err)
+	}
+	if state.PaymentState != easypay.StatusConfirmed {
+		t.Errorf("order is %s after Pay, want %s", state.PaymentState, easypay.StatusConfirmed)
+	}
+}
+
+// post sends a signed request of the default merchant the way the client does, with a fresh app and page
+func post(t *testing.T, server *easypaytest.Server, path string, body string) *easypay.Response {
+	t.Helper()
+
+	send := func(path string, headers map[string]string, body string) *easypay.Response {
+		request, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("NewRequest error = %v", err)
+		}
+
+		request.Header.Set("PartnerKey", easypaytest.DefaultPartnerKey)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s error = %v", path, err)
+		}
+		defer response.Body.Close()
+
+		decoded := &easypay.Response{}
+		if err = json.NewDecoder(response.Body).Decode(decoded); err != nil {
+			t.Fatalf("%s response error = %v", path, err)
+		}
+
+		return decoded
+	}
+
+	appID := *send(consts.CreateAppPath, nil, "").AppId
+	pageID := *send(consts.CreatePagePath, map[string]string{"AppId": appID}, "").PageId
+
+	return send(
+		path, map[string]string{
+			"AppId":  appID,
+			"PageId": pageID,
+			"Sign":   easypayhttp.ComputeSignature(easypaytest.DefaultSecretKey, body),
+		}, body,
+	)
+}
+
+func TestServerCaptureAmount(t *testing.T) {
+	server := easypaytest.NewServer()
+	defer server.Close()
+
+	hold(t, server.Client(), server.Merchant(), "order-1")
+
+	// an amount that is sent must be positive, the whole hold is captured only without one
+	response := post(t, server, consts.UnHoldPath, `{"orderId": "order-1", "amount": 0.00}`)
+	if !errors.Is(response.GetError(), easypay.ErrInvalidAmount) {
+		t.Fatalf("unHoldOrder of 0.00 error = %v, want ErrInvalidAmount", response.GetError())
+	}
+
+	if order, _ := server.Order("order-1"); order.State != easypay.PaymentHold {
+		t.Fatalf("order is %s after a rejected capture, want it held", order.State)
+	}
+
+	capture, err := go_easypay.NewCapture(server.Merchant()).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+	if _, err = server.Client().Capture(capture); err != nil {
+		t.Fatalf("Capture error = %v", err)
+	}
+
+	if order, _ := server.Order("order-1"); order.State != easypay.StatusConfirmed || order.Captured.MinorUnits() != 1000 {
+		t.Errorf("order is %s, captured %s, want the whole hold captured", order.State, order.Captured)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package easypaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	easypayhttp "github.com/stremovskyy/go-easypay/internal/http"
+)
+
+// Delivery is a notification the server sent
+type Delivery struct {
+	URL        string
+	Body       []byte
+	StatusCode int
+	Err        error
+}
+
+type notification struct {
+	url       string
+	secretKey string
+	webhook   *easypay.Webhook
+}
+
+// Deliveries returns the notifications sent so far
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+func (s *Server) webhookTarget(orderURL string) string {
+	if s.webhookURL != "" {
+		return s.webhookURL
+	}
+
+	return orderURL
+}
+
+func (s *Server) orderNotification(o *Order, action string, amount currency.Money) notification {
+	return notification{
+		url:       s.webhookTarget(o.WebhookURL),
+		secretKey: s.merchants[o.PartnerKey],
+		webhook: &easypay.Webhook{
+			PartnerKey: o.PartnerKey,
+			CardGuid:   o.CardGuid,
+			Action:     action,
+			OrderId:    o.OrderID,
+			Version:    "v3.0",
+			Date:       time.Now(),
+			Details: &easypay.WebhookDetails{
+				Amount:    amount,
+				Desc:      o.Description,
+				PaymentId: int(o.TransactionID),
+			},
+			Additionalitems: &easypay.Additionalitems{
+				MerchantKey:       o.ServiceKey,
+				MerchantOrderId:   o.OrderID,
+				MerchantUrlNotify: o.WebhookURL,
+			},
+		},
+	}
+}
+
+// deliver posts the signed notification, it is called without the lock so handlers may call the server
+func (s *Server) deliver(n notification) {
+	if n.url == "" {
+		return
+	}
+
+	delivery := s.post(n)
+
+	s.mu.Lock()
+	s.deliveries = append(s.deliveries, delivery)
+	s.mu.Unlock()
+}
+
+func (s *Server) post(n notification) Delivery {
+	delivery := Delivery{URL: n.url}
+
+	body, err := json.Marshal(n.webhook)
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+	delivery.Body = body
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(go_easypay.WebhookSignatureHeader, easypayhttp.ComputeSignature(n.secretKey, string(body)))
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+	_ = resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+
+	return delivery
+}
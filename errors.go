@@ -29,3 +29,28 @@ import "errors"
 var ErrRequestIsNil = errors.New("request is nil")
 var ErrMerchantIsNil = errors.New("merchant is nil")
 var ErrPersonalDataIsNil = errors.New("personal data is nil")
+var ErrRecurrentIsNil = errors.New("recurrent is nil")
+var ErrFailedCountIsNegative = errors.New("recurrent failed count cannot be negative")
+var ErrExpireDateIsZero = errors.New("recurrent expire date is required")
+var ErrExpireDateBeforeRun = errors.New("recurrent expire date must be after the run date")
+var ErrInvalidSplit = errors.New("split is invalid")
+var ErrSplitsMismatch = errors.New("splits do not add up to the payment")
+var ErrUnsupportedCurrency = errors.New("currency is not supported")
+var ErrCurrencyMismatch = errors.New("amount currency does not match payment currency")
+var ErrWebhookSignatureMissing = errors.New("webhook signature is missing")
+var ErrWebhookSignatureInvalid = errors.New("webhook signature is invalid")
+var ErrOperationInProgress = errors.New("operation with this payment ID is in progress")
+var ErrOperationOutcomeUnknown = errors.New("operation outcome is unknown, repeat it with the same payment ID")
+var ErrOperationNotApplied = errors.New("operation did not take effect, the order is in another state")
+var ErrPaymentDataIsNil = errors.New("payment data is nil")
+var ErrPaymentIDIsNil = errors.New("payment ID is nil")
+var ErrOrderReferenceIsNil = errors.New("payment ID or Easypay payment ID is required")
+var ErrAmountIsNotPositive = errors.New("amount must be positive")
+var ErrAmountIsNegative = errors.New("amount cannot be negative")
+var ErrPaymentMethodIsNil = errors.New("payment method is nil")
+var ErrPaymentInstrumentIsNil = errors.New("card token, Apple Pay container or Google Pay token is required")
+var ErrPartnerKeyIsEmpty = errors.New("merchant partner key is empty")
+var ErrRefundExceedsRemaining = errors.New("refund exceeds the remaining refundable amount")
+var ErrOrderIsNotHeld = errors.New("order is not held, only a hold can be voided")
+var ErrCreditNotSupported = errors.New("credit is not supported until the Easypay payout contract is confirmed")
+var ErrOrderIsHeld = errors.New("order is held and was not captured, release it with VoidHold instead of refunding it")
@@ -63,7 +63,7 @@ func main() {
 		},
 		PaymentData: &go_easypay.PaymentData{
 			PaymentID:   utils.Ref(uuidString),
-			Amount:      1.0,
+			Amount:      currency.New(100, currency.UAH),
 			Currency:    currency.UAH,
 			OrderID:     uuidString,
 			Description: "Test payment: " + uuidString,
@@ -0,0 +1,83 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/log"
+	"github.com/stremovskyy/go-easypay/private"
+)
+
+func main() {
+	client := go_easypay.NewDefaultClient()
+	client.SetLogLevel(log.LevelDebug)
+
+	merchant := &go_easypay.Merchant{
+		Name:       private.MerchantName,
+		PartnerKey: private.PartnerKey,
+		ServiceKey: private.ServiceKey,
+		SecretKey:  private.SecretKey,
+	}
+
+	orderID := uuid.New().String()
+
+	holdRequest, err := go_easypay.NewHold(merchant).
+		Amount(currency.New(100, currency.UAH)).
+		Order(orderID).
+		Description("Test hold: " + orderID).
+		Card(private.CardToken).
+		Webhook(private.WebhookURL).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+
+	holdResponse, err := client.Hold(holdRequest)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Hold: %s is %s\n", orderID, holdResponse.PaymentState)
+
+	captureRequest, err := go_easypay.NewCapture(merchant).
+		Order(orderID).
+		Amount(currency.New(60, currency.UAH)).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+
+	captureResponse, err := client.Capture(captureRequest)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Capture: %s is %s\n", orderID, captureResponse.PaymentState)
+}
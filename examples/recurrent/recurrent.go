@@ -0,0 +1,86 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+	"github.com/stremovskyy/go-easypay/log"
+	"github.com/stremovskyy/go-easypay/private"
+)
+
+func main() {
+	client := go_easypay.NewDefaultClient()
+
+	merchant := &go_easypay.Merchant{
+		Name:       private.MerchantName,
+		PartnerKey: private.PartnerKey,
+		ServiceKey: private.ServiceKey,
+		SecretKey:  private.SecretKey,
+	}
+
+	uuidString := uuid.New().String()
+
+	recurrentRequest := &go_easypay.Request{
+		Merchant: merchant,
+		PaymentMethod: &go_easypay.PaymentMethod{
+			Card: &go_easypay.Card{
+				Name:  private.CardID,
+				Token: utils.Ref(private.CardToken),
+			},
+		},
+		PaymentData: &go_easypay.PaymentData{
+			PaymentID:   utils.Ref(uuidString),
+			Amount:      currency.New(100, currency.UAH),
+			Currency:    currency.UAH,
+			OrderID:     uuidString,
+			Description: "Test subscription: " + uuidString,
+		},
+		Recurrent: &go_easypay.Recurrent{
+			CronRule:    easypay.Monthly(1, 10, 0),
+			DateExpire:  time.Now().AddDate(1, 0, 0),
+			FailedCount: 3,
+			FailedRule:  easypay.Daily(12, 0),
+		},
+	}
+
+	client.SetLogLevel(log.LevelDebug)
+	recurrentRequest.SetWebhookURL(utils.Ref(private.WebhookURL))
+
+	recurrentResponse, err := client.CreateRecurrent(recurrentRequest)
+	if err != nil {
+		panic(err)
+	}
+
+	// the ID of the recurrent payment is sent to the webhook with the notifications of its charges
+	fmt.Printf("Recurrent: %s first charge is %s\n", uuidString, recurrentResponse.PaymentState)
+}
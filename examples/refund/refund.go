@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/currency"
 	"github.com/stremovskyy/go-easypay/internal/utils"
 	"github.com/stremovskyy/go-easypay/log"
 	"github.com/stremovskyy/go-easypay/private"
@@ -54,7 +55,7 @@ func main() {
 		Merchant: merchant,
 		PaymentData: &go_easypay.PaymentData{
 			EasypayPaymentID: utils.Ref(int64(private.EasypayPaymentID)),
-			Amount:           1.0,
+			Amount:           currency.New(100, currency.UAH),
 			PaymentID:        utils.Ref(private.EasypayOrderID),
 		},
 	}
@@ -71,5 +72,10 @@ func main() {
 		panic(refundResponse.GetError())
 	}
 
-	fmt.Printf("Payment is %s", refundResponse.PaymentState)
+	remaining, err := refundResponse.Ledger.Remaining()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Payment is %s, %s left to refund", refundResponse.PaymentState, remaining)
 }
@@ -0,0 +1,65 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/private"
+	"github.com/stremovskyy/go-easypay/webhook"
+)
+
+func main() {
+	merchant := &go_easypay.Merchant{
+		Name:       private.MerchantName,
+		PartnerKey: private.PartnerKey,
+		ServiceKey: private.ServiceKey,
+		SecretKey:  private.SecretKey,
+	}
+
+	handler := webhook.NewHandler(merchant).
+		OnCardTokenized(
+			func(ctx context.Context, event *easypay.CardTokenizedEvent) error {
+				fmt.Printf("Card token: %s\n", event.CardGuid)
+				return nil
+			},
+		).
+		OnPayment(
+			func(ctx context.Context, event *easypay.PaymentEvent) error {
+				fmt.Printf("Payment: %s is %s\n", event.OrderID, event.Amount)
+				return nil
+			},
+		)
+
+	http.Handle("/easypay/webhook", handler)
+
+	if err := http.ListenAndServe(":8080", nil); err != nil {
+		panic(err)
+	}
+}
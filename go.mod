@@ -3,12 +3,14 @@ module github.com/stremovskyy/go-easypay
 go 1.22
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/google/uuid v1.6.0
+	github.com/redis/go-redis/v9 v9.11.0
 	github.com/stremovskyy/recorder v1.0.0
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/redis/go-redis/v9 v9.11.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 )
@@ -0,0 +1,392 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/http"
+	"github.com/stremovskyy/go-easypay/log"
+)
+
+// operationLease is how long an in-flight operation is assumed to be still running in another call
+const operationLease = 2 * time.Minute
+
+// resolveTimeout bounds the order state check made after the caller's context was cancelled
+const resolveTimeout = 30 * time.Second
+
+type sendFunc func(ctx context.Context, request *Request) (*easypay.Response, error)
+
+// checkFunc validates the order before the request is sent, a failed check sends nothing
+type checkFunc func() error
+
+// newOperationRecord describes the operation for the idempotency store, nil when the request has no payment ID
+func newOperationRecord(op Operation, request *Request) *OperationRecord {
+	if request.GetPaymentID() == nil {
+		return nil
+	}
+
+	return &OperationRecord{
+		Key:       string(op) + ":" + *request.GetPaymentID(),
+		Operation: op,
+		PaymentID: *request.GetPaymentID(),
+		StartedAt: time.Now(),
+	}
+}
+
+// newRefundRecord describes a refund, the refunds of an order are told apart by the caller's refund ID or,
+// without one, by the requested amount. amount is the resolved amount of the refund.
+func newRefundRecord(request *Request, amount currency.Money, refundCount int) *OperationRecord {
+	record := newOperationRecord(OperationRefund, request)
+	if record == nil {
+		return nil
+	}
+
+	if refundID := request.GetRefundID(); refundID != "" {
+		record.Key += ":id:" + refundID
+	} else {
+		record.Key += ":amount:" + request.GetAmount().Decimal()
+	}
+
+	record.Amount = amount
+	record.RefundCount = refundCount
+
+	return record
+}
+
+// idempotent sends a money moving request at most once per payment ID. When the outcome of the call
+// is unknown the order state is checked before the request is sent again. An operation left by a previous
+// call is resolved before check runs, so repeating an operation that took effect is not rejected by it.
+func (c *client) idempotent(ctx context.Context, record *OperationRecord, request *Request, check checkFunc, send sendFunc) (*easypay.Response, error) {
+	if check == nil {
+		check = func() error { return nil }
+	}
+
+	if c.idempotency == nil || record == nil {
+		if err := check(); err != nil {
+			return nil, err
+		}
+
+		return send(ctx, request)
+	}
+
+	op := record.Operation
+
+	existing, err := c.idempotency.Begin(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("cannot begin %s operation: %w", op, err)
+	}
+
+	if existing != nil {
+		response, applied, err := c.resolveOperation(ctx, request, existing)
+		if errors.Is(err, ErrOperationNotApplied) {
+			c.finishOperation(ctx, existing)
+
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrOperationOutcomeUnknown, err)
+		}
+
+		if applied {
+			c.finishOperation(ctx, existing)
+
+			return response, nil
+		}
+
+		if time.Since(existing.StartedAt) < operationLease {
+			return nil, ErrOperationInProgress
+		}
+
+		// the previous attempt never reached Easypay, it is taken over by this call
+		record = existing
+		record.StartedAt = time.Now()
+		if err = c.idempotency.Put(ctx, record); err != nil {
+			return nil, fmt.Errorf("cannot update %s operation: %w", op, err)
+		}
+	}
+
+	if err = check(); err != nil {
+		c.finishOperation(ctx, record)
+
+		return nil, err
+	}
+
+	response, err := send(ctx, request)
+	if !isAmbiguous(err) {
+		c.finishOperation(ctx, record)
+
+		return response, err
+	}
+
+	resolveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resolveTimeout)
+	defer cancel()
+
+	stateResponse, applied, resolveErr := c.resolveOperation(resolveCtx, request, record)
+	if errors.Is(resolveErr, ErrOperationNotApplied) {
+		c.finishOperation(resolveCtx, record)
+
+		return nil, fmt.Errorf("%w: %w", resolveErr, err)
+	}
+	if resolveErr != nil {
+		// the record stays, the next call with the same payment ID resolves it
+		return nil, fmt.Errorf("%w: %w, order state check failed: %w", ErrOperationOutcomeUnknown, err, resolveErr)
+	}
+
+	if applied {
+		c.finishOperation(resolveCtx, record)
+
+		return stateResponse, nil
+	}
+
+	if ctx.Err() != nil {
+		// Easypay may still be processing the request, the record stays so the next call resolves it
+		return nil, fmt.Errorf("%w: %w", ErrOperationOutcomeUnknown, err)
+	}
+
+	log.NewLogger("easypay").Warning("%s %s did not reach Easypay, sending again: %v", op, record.PaymentID, err)
+
+	response, err = send(ctx, request)
+	if isAmbiguous(err) {
+		return nil, fmt.Errorf("%w: %w", ErrOperationOutcomeUnknown, err)
+	}
+
+	c.finishOperation(ctx, record)
+
+	return response, err
+}
+
+// resolveOperation checks the order state to learn whether the operation took effect. An order in a state
+// the operation cannot lead to is reported with ErrOperationNotApplied, sending the request again would not help.
+// A payment or hold still waiting for the customer is reported with ErrOperationInProgress, its record is kept.
+func (c *client) resolveOperation(ctx context.Context, request *Request, record *OperationRecord) (*easypay.Response, bool, error) {
+	state, err := c.OrderStateCtx(ctx, request)
+	if errors.Is(err, easypay.ErrOrderNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch record.Operation {
+	case OperationPayment, OperationHold:
+		// the customer may still complete the order, it is neither applied nor failed yet
+		if isUnfinished(state.PaymentState) {
+			return nil, false, fmt.Errorf("%w: order %s is %s", ErrOperationInProgress, state.OrderID, state.PaymentState)
+		}
+	}
+
+	applied := false
+	switch record.Operation {
+	case OperationPayment:
+		sameAmount, err := equalAmounts(state.Amount, request.GetAmount())
+		if err != nil {
+			return nil, false, err
+		}
+
+		applied = state.PaymentState == easypay.StatusConfirmed && sameAmount
+		if !applied {
+			// the order exists, createOrder reached Easypay and sending it again is rejected as a duplicate
+			return nil, false, notApplied(record, state)
+		}
+	case OperationHold:
+		sameAmount, err := equalAmounts(state.Amount, request.GetAmount())
+		if err != nil {
+			return nil, false, err
+		}
+
+		// a hold that was captured since is confirmed
+		applied = (state.IsHeld() || state.PaymentState == easypay.StatusConfirmed) && sameAmount
+		if !applied {
+			return nil, false, notApplied(record, state)
+		}
+	case OperationCapture:
+		if state.IsHeld() {
+			return nil, false, nil
+		}
+
+		captured, err := capturedAmount(state)
+		if err != nil {
+			return nil, false, err
+		}
+
+		sameAmount, err := equalAmounts(captured, captureAmount(request, state))
+		if err != nil {
+			return nil, false, err
+		}
+
+		applied = state.PaymentState == easypay.StatusConfirmed && sameAmount
+		if !applied {
+			return nil, false, notApplied(record, state)
+		}
+	case OperationVoid:
+		if state.IsHeld() {
+			return nil, false, nil
+		}
+
+		applied = state.PaymentState == easypay.StatusRefunded || state.PaymentState == easypay.StatusCancelingAccepted
+		if !applied {
+			return nil, false, notApplied(record, state)
+		}
+	case OperationRefund:
+		refund := newRefund(state, record)
+		if refund == nil {
+			return nil, false, nil
+		}
+
+		return responseFromState(state, refund), true, nil
+	}
+
+	if !applied {
+		return nil, false, nil
+	}
+
+	return responseFromState(state, nil), true, nil
+}
+
+// isUnfinished reports whether an order in the state can still be confirmed or held
+func isUnfinished(status easypay.Status) bool {
+	switch status {
+	case easypay.StatusPending, easypay.StatusWaitVerify, easypay.StatusWaitConfirm:
+		return true
+	}
+
+	return false
+}
+
+// newRefund finds the refund of the record among the refunds made since it was sent, nil when there is none
+func newRefund(state *easypay.PaymentStatusResponse, record *OperationRecord) *easypay.PaymentDetail {
+	refunds := state.Refunds()
+	if record.RefundCount >= len(refunds) {
+		return nil
+	}
+
+	for _, refund := range refunds[record.RefundCount:] {
+		if sameAmount, err := equalAmounts(refund.Amount, record.Amount); err == nil && sameAmount {
+			return &refund
+		}
+	}
+
+	return nil
+}
+
+func notApplied(record *OperationRecord, state *easypay.PaymentStatusResponse) error {
+	return fmt.Errorf("%w: %s of order %s, the order is %s for %s", ErrOperationNotApplied, record.Operation, record.PaymentID, state.PaymentState, state.Amount)
+}
+
+// captureAmount is the amount the capture request charges, the whole hold when no amount is set
+func captureAmount(request *Request, state *easypay.PaymentStatusResponse) currency.Money {
+	if amount := request.GetAmount(); !amount.IsZero() {
+		return amount
+	}
+
+	return state.Amount
+}
+
+// capturedAmount is the amount charged for the order, orders without child payments are charged as a whole
+func capturedAmount(state *easypay.PaymentStatusResponse) (currency.Money, error) {
+	payments := state.Payments()
+	if len(payments) == 0 {
+		return state.Amount, nil
+	}
+
+	captured := currency.New(0, state.Amount.Currency())
+	for _, payment := range payments {
+		sum, err := captured.AddChecked(payment.Amount)
+		if err != nil {
+			return currency.Money{}, fmt.Errorf("payment %d: %w", payment.TransactionID, err)
+		}
+
+		captured = sum
+	}
+
+	return captured, nil
+}
+
+// equalAmounts compares the amounts, an amount in another currency is an error rather than a mismatch
+func equalAmounts(a currency.Money, b currency.Money) (bool, error) {
+	cmp, err := a.Compare(b)
+	if err != nil {
+		return false, err
+	}
+
+	return cmp == 0, nil
+}
+
+// finishOperation forgets a resolved operation, a failure only leaves a record that the next call resolves
+func (c *client) finishOperation(ctx context.Context, record *OperationRecord) {
+	if err := c.idempotency.Finish(ctx, record.Key); err != nil {
+		log.NewLogger("easypay").Error("cannot finish %s operation %s: %v", record.Operation, record.PaymentID, err)
+	}
+}
+
+// isAmbiguous reports whether the request may have reached Easypay although the call failed
+func isAmbiguous(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// the request never left the client when the session could not be created or the request could not be built
+	var sessionErr *sessionError
+	if errors.As(err, &sessionErr) || errors.Is(err, http.ErrNotSent) {
+		return false
+	}
+
+	if errors.Is(err, easypay.ErrDuplicateOrder) || errors.Is(err, easypay.ErrTemporary) {
+		return true
+	}
+
+	_, rejected := easypay.AsAPIError(err)
+
+	return !rejected
+}
+
+// responseFromState describes the resolved operation, refund is the refund it made if any
+func responseFromState(state *easypay.PaymentStatusResponse, refund *easypay.PaymentDetail) *easypay.Response {
+	transactionID := state.TransactionID
+	orderID := state.OrderID
+	amount := state.Amount
+
+	response := &easypay.Response{
+		PaymentState:  state.PaymentState,
+		TransactionId: &transactionID,
+		OrderId:       &orderID,
+		Amount:        &amount,
+		PaymentsList:  state.PaymentsList,
+	}
+
+	if refund != nil {
+		refundID := int(refund.RefundTransactionID)
+		response.RefundTransactionId = &refundID
+		response.Amount = &refund.Amount
+	}
+
+	return response
+}
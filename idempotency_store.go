@@ -0,0 +1,195 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
+// defaultOperationTTL keeps unresolved operations long enough to outlive a hold
+const defaultOperationTTL = 7 * 24 * time.Hour
+
+// operationBeginAttempts bounds the retries of a Redis Begin whose key is removed while it is read
+const operationBeginAttempts = 3
+
+// OperationRecord describes a money moving call that was sent to Easypay and has no known outcome yet
+type OperationRecord struct {
+	Key       string    `json:"key"`
+	Operation Operation `json:"operation"`
+	PaymentID string    `json:"paymentId"`
+	// RefundCount is the number of refunds the order had before a refund was sent
+	RefundCount int `json:"refundCount,omitempty"`
+	// Amount is the amount of the refund
+	Amount    currency.Money `json:"amount"`
+	StartedAt time.Time      `json:"startedAt"`
+}
+
+// IdempotencyStore keeps in-flight operations, a persistent store lets a restarted process
+// resolve operations that were interrupted before their outcome was known
+type IdempotencyStore interface {
+	// Begin stores the record unless one exists for its key, in which case the existing record is returned
+	Begin(ctx context.Context, record *OperationRecord) (*OperationRecord, error)
+	// Put replaces the stored record
+	Put(ctx context.Context, record *OperationRecord) error
+	// Finish forgets the record once the outcome of the operation is known
+	Finish(ctx context.Context, key string) error
+}
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]OperationRecord
+}
+
+// NewMemoryIdempotencyStore keeps in-flight operations in memory, they are lost when the process exits
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{
+		records: make(map[string]OperationRecord),
+	}
+}
+
+func (s *memoryIdempotencyStore) Begin(_ context.Context, record *OperationRecord) (*OperationRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[record.Key]; ok {
+		return &existing, nil
+	}
+
+	s.records[record.Key] = *record
+
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Put(_ context.Context, record *OperationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[record.Key] = *record
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Finish(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+type redisIdempotencyStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisIdempotencyStore keeps in-flight operations in Redis for ttl, so they survive restarts
+// and are shared by every instance of the service
+func NewRedisIdempotencyStore(client redis.UniversalClient, prefix string, ttl time.Duration) IdempotencyStore {
+	if ttl <= 0 {
+		ttl = defaultOperationTTL
+	}
+
+	if prefix == "" {
+		prefix = "easypay:operation"
+	}
+
+	return &redisIdempotencyStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (s *redisIdempotencyStore) Begin(ctx context.Context, record *OperationRecord) (*OperationRecord, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal operation record: %w", err)
+	}
+
+	for attempt := 0; attempt < operationBeginAttempts; attempt++ {
+		stored, err := s.client.SetNX(ctx, s.key(record.Key), data, s.ttl).Result()
+		if err != nil {
+			return nil, fmt.Errorf("cannot store operation record: %w", err)
+		}
+
+		if stored {
+			return nil, nil
+		}
+
+		raw, err := s.client.Get(ctx, s.key(record.Key)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// the record was finished or expired in between, the key is free to be stored again
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot load operation record: %w", err)
+		}
+
+		existing := &OperationRecord{}
+		if err = json.Unmarshal(raw, existing); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal operation record: %w", err)
+		}
+
+		return existing, nil
+	}
+
+	return nil, fmt.Errorf("cannot store operation record: it was removed %d times while being stored", operationBeginAttempts)
+}
+
+func (s *redisIdempotencyStore) Put(ctx context.Context, record *OperationRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("cannot marshal operation record: %w", err)
+	}
+
+	if err = s.client.Set(ctx, s.key(record.Key), data, s.ttl).Err(); err != nil {
+		return fmt.Errorf("cannot store operation record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisIdempotencyStore) Finish(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.key(key)).Err(); err != nil {
+		return fmt.Errorf("cannot delete operation record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisIdempotencyStore) key(key string) string {
+	return s.prefix + ":" + key
+}
@@ -0,0 +1,223 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/stremovskyy/go-easypay/currency"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	testIdempotencyStore(t, NewMemoryIdempotencyStore())
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	testIdempotencyStore(t, NewRedisIdempotencyStore(client, "", 0))
+}
+
+func TestRedisIdempotencyStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	store := NewRedisIdempotencyStore(client, "", time.Hour)
+	record := &OperationRecord{Key: "payment:order-1", Operation: OperationPayment, PaymentID: "order-1", StartedAt: time.Now()}
+
+	if _, err := store.Begin(ctx, record); err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+
+	if !server.Exists("easypay:operation:payment:order-1") {
+		t.Fatal("record is not stored under the default prefix")
+	}
+	if ttl := server.TTL("easypay:operation:payment:order-1"); ttl != time.Hour {
+		t.Fatalf("record TTL = %v, want the store TTL", ttl)
+	}
+
+	// a taken over record is kept for the whole TTL again
+	server.FastForward(30 * time.Minute)
+	if err := store.Put(ctx, record); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	if ttl := server.TTL("easypay:operation:payment:order-1"); ttl != time.Hour {
+		t.Fatalf("record TTL after Put = %v, want the store TTL", ttl)
+	}
+
+	// an expired record is forgotten, the next call starts the operation again
+	server.FastForward(time.Hour)
+	existing, err := store.Begin(ctx, record)
+	if err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+	if existing != nil {
+		t.Fatalf("Begin returned the expired record %+v", existing)
+	}
+}
+
+// expiringGetClient deletes the key right before the first Get, as if it expired between SetNX and Get
+type expiringGetClient struct {
+	redis.UniversalClient
+	server  *miniredis.Miniredis
+	expired bool
+}
+
+func (c *expiringGetClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	if !c.expired {
+		c.expired = true
+		c.server.Del(key)
+	}
+
+	return c.UniversalClient.Get(ctx, key)
+}
+
+func TestRedisIdempotencyStoreRecordExpiresWhileRead(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	record := &OperationRecord{Key: "payment:order-1", Operation: OperationPayment, PaymentID: "order-1", StartedAt: time.Now()}
+
+	t.Run("stored on retry", func(t *testing.T) {
+		if err := server.Set("test:payment:order-1", `{"key":"payment:order-1"}`); err != nil {
+			t.Fatalf("Set error = %v", err)
+		}
+
+		store := NewRedisIdempotencyStore(&expiringGetClient{UniversalClient: client, server: server}, "test", time.Hour)
+
+		existing, err := store.Begin(ctx, record)
+		if err != nil {
+			t.Fatalf("Begin error = %v", err)
+		}
+		if existing != nil {
+			t.Fatalf("Begin returned %+v, want the record stored", existing)
+		}
+		if ttl := server.TTL("test:payment:order-1"); ttl != time.Hour {
+			t.Errorf("record TTL = %v, want the store TTL", ttl)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		server.FlushAll()
+
+		// another caller stores the key before every SetNX and it is gone before every Get
+		taken := &setNXTakenClient{UniversalClient: client}
+		store := NewRedisIdempotencyStore(taken, "test", time.Hour)
+
+		if _, err := store.Begin(ctx, record); err == nil {
+			t.Fatal("Begin error = nil, want it to give up")
+		}
+		if taken.calls != operationBeginAttempts {
+			t.Errorf("SetNX called %d times, want %d", taken.calls, operationBeginAttempts)
+		}
+	})
+}
+
+// setNXTakenClient reports every key as taken, as if another caller stored it right before every SetNX
+type setNXTakenClient struct {
+	redis.UniversalClient
+	calls int
+}
+
+func (c *setNXTakenClient) SetNX(ctx context.Context, key string, _ interface{}, _ time.Duration) *redis.BoolCmd {
+	c.calls++
+
+	cmd := redis.NewBoolCmd(ctx, "setnx", key)
+	cmd.SetVal(false)
+
+	return cmd
+}
+
+// testIdempotencyStore runs the behaviour shared by every store
+func testIdempotencyStore(t *testing.T, store IdempotencyStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	record := &OperationRecord{
+		Key:         "refund:order-1:id:refund-1",
+		Operation:   OperationRefund,
+		PaymentID:   "order-1",
+		RefundCount: 2,
+		Amount:      currency.New(250, currency.UAH),
+		StartedAt:   time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+
+	begin := func() *OperationRecord {
+		t.Helper()
+
+		existing, err := store.Begin(ctx, record)
+		if err != nil {
+			t.Fatalf("Begin error = %v", err)
+		}
+
+		return existing
+	}
+
+	if existing := begin(); existing != nil {
+		t.Fatalf("first Begin returned %+v, want the record stored", existing)
+	}
+
+	existing := begin()
+	if existing == nil {
+		t.Fatal("second Begin returned nil, want the stored record")
+	}
+	if existing.Key != record.Key || existing.Operation != record.Operation || existing.PaymentID != record.PaymentID ||
+		existing.RefundCount != record.RefundCount || existing.Amount.MinorUnits() != 250 || !existing.StartedAt.Equal(record.StartedAt) {
+		t.Errorf("stored record = %+v, want %+v", existing, record)
+	}
+
+	taken := *record
+	taken.StartedAt = time.Now().Truncate(time.Second)
+	if err := store.Put(ctx, &taken); err != nil {
+		t.Fatalf("Put error = %v", err)
+	}
+	if existing = begin(); existing == nil || !existing.StartedAt.Equal(taken.StartedAt) {
+		t.Errorf("record after Put = %+v, want it started at %s", existing, taken.StartedAt)
+	}
+
+	if err := store.Finish(ctx, record.Key); err != nil {
+		t.Fatalf("Finish error = %v", err)
+	}
+	if existing = begin(); existing != nil {
+		t.Errorf("Begin after Finish returned %+v, want the record stored again", existing)
+	}
+
+	// finishing an unknown key is not an error
+	if err := store.Finish(ctx, "payment:unknown"); err != nil {
+		t.Errorf("Finish of an unknown key error = %v", err)
+	}
+}
@@ -0,0 +1,582 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+func orderNotFound(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte(`{"error": {"errorCode": "ORDER_NOT_FOUND"}}`))
+}
+
+func newIdempotentPayment(t *testing.T) *Request {
+	request, err := NewPayment(testMerchant).
+		Order("order-1").
+		Amount(currency.New(1000, currency.UAH)).
+		Card("token").
+		Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	return request
+}
+
+func TestIdempotentKeepsRecordWhenCancelled(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			// the order is created only after the caller gave up
+			consts.CreateOrderPath: func(w http.ResponseWriter, r *http.Request) {
+				// the server notices the closed connection only after the body was read
+				_, _ = io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			},
+			consts.CheckOrderStatePath: orderNotFound,
+		},
+	)
+
+	store := NewMemoryIdempotencyStore()
+	c := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil), WithIdempotencyStore(store))
+	request := newIdempotentPayment(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := c.PaymentCtx(ctx, request)
+	if !errors.Is(err, ErrOperationOutcomeUnknown) {
+		t.Fatalf("PaymentCtx error = %v, want ErrOperationOutcomeUnknown", err)
+	}
+
+	existing, err := store.Begin(context.Background(), newOperationRecord(OperationPayment, request))
+	if err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+	if existing == nil {
+		t.Fatal("the record of the cancelled payment was forgotten")
+	}
+
+	if _, err = c.Payment(request); !errors.Is(err, ErrOperationInProgress) {
+		t.Errorf("repeated Payment error = %v, want ErrOperationInProgress", err)
+	}
+}
+
+func TestIdempotentWrapsStateCheckError(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+		},
+	)
+
+	c := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil), WithIdempotencyStore(NewMemoryIdempotencyStore()))
+
+	_, err := c.Payment(newIdempotentPayment(t))
+	if !errors.Is(err, ErrOperationOutcomeUnknown) {
+		t.Fatalf("Payment error = %v, want ErrOperationOutcomeUnknown", err)
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Payment error %v does not wrap several errors", err)
+	}
+
+	var statuses []int
+	for _, wrapped := range joined.Unwrap() {
+		if httpError, ok := easypay.AsHTTPError(wrapped); ok {
+			statuses = append(statuses, httpError.StatusCode)
+		}
+	}
+
+	if len(statuses) != 2 || statuses[0] != http.StatusBadGateway || statuses[1] != http.StatusServiceUnavailable {
+		t.Errorf("Payment error wraps HTTP statuses %v, want the send and the state check failures: %v", statuses, err)
+	}
+}
+
+func TestIdempotentForgetsOperationWhenSessionFails(t *testing.T) {
+	var createAppFails atomic.Bool
+	createAppFails.Store(true)
+
+	var orders atomic.Int32
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CreateAppPath: func(w http.ResponseWriter, _ *http.Request) {
+				if createAppFails.Load() {
+					http.Error(w, "bad gateway", http.StatusBadGateway)
+					return
+				}
+				_, _ = w.Write([]byte(`{"appId": "app"}`))
+			},
+			consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				orders.Add(1)
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`))
+			},
+		},
+	)
+
+	store := NewMemoryIdempotencyStore()
+	c := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil), WithIdempotencyStore(store))
+	request := newIdempotentPayment(t)
+
+	_, err := c.Payment(request)
+	if err == nil || errors.Is(err, ErrOperationOutcomeUnknown) {
+		t.Fatalf("Payment error = %v, want the createApp failure", err)
+	}
+	if httpError, ok := easypay.AsHTTPError(err); !ok || httpError.StatusCode != http.StatusBadGateway {
+		t.Errorf("Payment error = %v, want the createApp HTTP error", err)
+	}
+	if n := orders.Load(); n != 0 {
+		t.Fatalf("createOrder called %d times without a session", n)
+	}
+
+	// the record was forgotten, so the payment is sent as soon as the session works again
+	createAppFails.Store(false)
+
+	response, err := c.Payment(request)
+	if err != nil {
+		t.Fatalf("repeated Payment error = %v", err)
+	}
+	if response.PaymentState != easypay.StatusConfirmed {
+		t.Errorf("payment is %s, want %s", response.PaymentState, easypay.StatusConfirmed)
+	}
+	if n := orders.Load(); n != 1 {
+		t.Errorf("createOrder called %d times, want 1", n)
+	}
+}
+
+var testMerchant = &Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret"}
+
+// ambiguousRecord stores the record an earlier call left when the outcome of its request was unknown
+func ambiguousRecord(t *testing.T, store IdempotencyStore, record *OperationRecord) {
+	record.StartedAt = time.Now().Add(-time.Hour)
+
+	if _, err := store.Begin(context.Background(), record); err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+}
+
+func assertRecordFinished(t *testing.T, store IdempotencyStore, record *OperationRecord) {
+	existing, err := store.Begin(context.Background(), record)
+	if err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+	if existing != nil {
+		t.Errorf("the record of the resolved %s was kept", record.Operation)
+	}
+}
+
+func TestRefundRepeatedAfterAmbiguousFullRefund(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			// the full refund took effect, nothing is left to refund
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Refunded",
+					"paymentsList": [{"transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"},
+					{"refundTransactionId": 7, "amount": 10.00, "paymentState": "Refunded"}]}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("a refund that took effect must not be sent again")
+			},
+		},
+	)
+
+	request, err := NewRefund(testMerchant).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	store := NewMemoryIdempotencyStore()
+	record := newRefundRecord(request, currency.New(1000, currency.UAH), 0)
+	ambiguousRecord(t, store, record)
+
+	response, err := NewClient(WithBaseURL(server.URL), WithIdempotencyStore(store)).Refund(request)
+	if err != nil {
+		t.Fatalf("Refund error = %v, want the resolved refund", err)
+	}
+
+	if response.RefundTransactionID != 7 {
+		t.Errorf("refund transaction = %d, want 7", response.RefundTransactionID)
+	}
+
+	assertRecordFinished(t, store, record)
+}
+
+func TestVoidHoldRepeatedAfterAmbiguousVoid(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			// the hold was released, the order is not held anymore
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Refunded"}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("a void that took effect must not be sent again")
+			},
+		},
+	)
+
+	request, err := NewVoidHold(testMerchant).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	store := NewMemoryIdempotencyStore()
+	record := newOperationRecord(OperationVoid, request)
+	ambiguousRecord(t, store, record)
+
+	response, err := NewClient(WithBaseURL(server.URL), WithIdempotencyStore(store)).VoidHold(request)
+	if err != nil {
+		t.Fatalf("VoidHold error = %v, want the resolved void", err)
+	}
+
+	if !response.IsAccepted() {
+		t.Errorf("VoidHold state = %s, want the released order", response.PaymentState)
+	}
+
+	assertRecordFinished(t, store, record)
+}
+
+func TestVoidHoldChecksOrderWithoutRecord(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Confirmed"}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("a captured order must not be cancelled by VoidHold")
+			},
+		},
+	)
+
+	request, err := NewVoidHold(testMerchant).Order("order-1").Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	store := NewMemoryIdempotencyStore()
+	c := NewClient(WithBaseURL(server.URL), WithIdempotencyStore(store))
+
+	if _, err = c.VoidHold(request); !errors.Is(err, ErrOrderIsNotHeld) {
+		t.Fatalf("VoidHold error = %v, want ErrOrderIsNotHeld", err)
+	}
+
+	// the rejected void leaves no record that would hold back the next call
+	assertRecordFinished(t, store, newOperationRecord(OperationVoid, request))
+}
+
+func TestResolveOperation(t *testing.T) {
+	var state string
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				if state == "" {
+					orderNotFound(w, nil)
+					return
+				}
+				_, _ = w.Write([]byte(state))
+			},
+		},
+	)
+
+	c := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil)).(*client)
+
+	build := func(builder *RequestBuilder) *Request {
+		request, err := builder.Order("order-1").Build()
+		if err != nil {
+			t.Fatalf("Build error = %v", err)
+		}
+
+		return request
+	}
+
+	amount := currency.New(1000, currency.UAH)
+	payment := build(NewPayment(testMerchant).Amount(amount).Card("token"))
+	hold := build(NewHold(testMerchant).Amount(amount).Card("token"))
+	capture := build(NewCapture(testMerchant))
+	partialCapture := build(NewCapture(testMerchant).Amount(currency.New(400, currency.UAH)))
+	void := build(NewVoidHold(testMerchant))
+
+	const (
+		confirmed        = `{"orderId": "order-1", "amount": 10.00, "paymentState": "Confirmed"}`
+		confirmedPartial = `{"orderId": "order-1", "amount": 10.00, "paymentState": "Confirmed", "paymentsList": [{"amount": 4.00, "paymentState": "Confirmed"}]}`
+		confirmedOther   = `{"orderId": "order-1", "amount": 25.00, "paymentState": "Confirmed"}`
+		held             = `{"orderId": "order-1", "amount": 10.00, "paymentState": "paymenthold"}`
+		rejected         = `{"orderId": "order-1", "amount": 10.00, "paymentState": "Rejected"}`
+		released         = `{"orderId": "order-1", "amount": 10.00, "paymentState": "Refunded"}`
+		pending          = `{"orderId": "order-1", "amount": 10.00, "paymentState": "Pending"}`
+		waitVerify       = `{"orderId": "order-1", "amount": 10.00, "paymentState": "WaitVerify"}`
+		waitConfirm      = `{"orderId": "order-1", "amount": 10.00, "paymentState": "WaitConfirm"}`
+	)
+
+	tests := []struct {
+		name        string
+		op          Operation
+		request     *Request
+		state       string
+		wantApplied bool
+		wantErr     error
+	}{
+		{name: "payment not created", op: OperationPayment, request: payment, state: ""},
+		{name: "payment confirmed", op: OperationPayment, request: payment, state: confirmed, wantApplied: true},
+		{name: "payment declined", op: OperationPayment, request: payment, state: rejected, wantErr: ErrOperationNotApplied},
+		{name: "payment of another amount", op: OperationPayment, request: payment, state: confirmedOther, wantErr: ErrOperationNotApplied},
+		{name: "payment held", op: OperationPayment, request: payment, state: held, wantErr: ErrOperationNotApplied},
+		{name: "payment pending", op: OperationPayment, request: payment, state: pending, wantErr: ErrOperationInProgress},
+		{name: "payment waiting for 3DS", op: OperationPayment, request: payment, state: waitVerify, wantErr: ErrOperationInProgress},
+		{name: "payment waiting for confirmation", op: OperationPayment, request: payment, state: waitConfirm, wantErr: ErrOperationInProgress},
+		{name: "hold pending", op: OperationHold, request: hold, state: pending, wantErr: ErrOperationInProgress},
+		{name: "hold waiting for 3DS", op: OperationHold, request: hold, state: waitVerify, wantErr: ErrOperationInProgress},
+		{name: "hold waiting for confirmation", op: OperationHold, request: hold, state: waitConfirm, wantErr: ErrOperationInProgress},
+		{name: "hold placed", op: OperationHold, request: hold, state: held, wantApplied: true},
+		{name: "hold captured since", op: OperationHold, request: hold, state: confirmed, wantApplied: true},
+		{name: "hold declined", op: OperationHold, request: hold, state: rejected, wantErr: ErrOperationNotApplied},
+		{name: "capture not sent", op: OperationCapture, request: capture, state: held},
+		{name: "capture of the whole hold", op: OperationCapture, request: capture, state: confirmed, wantApplied: true},
+		{name: "partial capture", op: OperationCapture, request: partialCapture, state: confirmedPartial, wantApplied: true},
+		{name: "capture of another amount", op: OperationCapture, request: capture, state: confirmedPartial, wantErr: ErrOperationNotApplied},
+		{name: "capture of a released hold", op: OperationCapture, request: capture, state: released, wantErr: ErrOperationNotApplied},
+		{name: "void not sent", op: OperationVoid, request: void, state: held},
+		{name: "void released the hold", op: OperationVoid, request: void, state: released, wantApplied: true},
+		{name: "void of a captured order", op: OperationVoid, request: void, state: confirmed, wantErr: ErrOperationNotApplied},
+	}
+
+	for _, tt := range tests {
+		state = tt.state
+
+		response, applied, err := c.resolveOperation(context.Background(), tt.request, newOperationRecord(tt.op, tt.request))
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: resolveOperation error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: resolveOperation error = %v", tt.name, err)
+			continue
+		}
+
+		if applied != tt.wantApplied || (response != nil) != tt.wantApplied {
+			t.Errorf("%s: resolveOperation applied = %v with response %v, want %v", tt.name, applied, response, tt.wantApplied)
+		}
+	}
+}
+
+func TestPaymentDuplicateOfDeclinedOrder(t *testing.T) {
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"error": {"errorCode": "DUPLICATE_ORDER"}}`))
+			},
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "Rejected"}`))
+			},
+		},
+	)
+
+	store := NewMemoryIdempotencyStore()
+	request := newIdempotentPayment(t)
+
+	_, err := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil), WithIdempotencyStore(store)).Payment(request)
+	if !errors.Is(err, ErrOperationNotApplied) {
+		t.Fatalf("Payment error = %v, want ErrOperationNotApplied", err)
+	}
+	if !errors.Is(err, easypay.ErrDuplicateOrder) {
+		t.Errorf("Payment error = %v, want the duplicate order rejection", err)
+	}
+
+	assertRecordFinished(t, store, newOperationRecord(OperationPayment, request))
+}
+
+func TestPaymentDuplicateOfPendingOrder(t *testing.T) {
+	var orders atomic.Int32
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			consts.CreateOrderPath: func(w http.ResponseWriter, _ *http.Request) {
+				orders.Add(1)
+				_, _ = w.Write([]byte(`{"error": {"errorCode": "DUPLICATE_ORDER"}}`))
+			},
+			// the customer has not finished 3DS yet
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 10.00, "paymentState": "WaitVerify"}`))
+			},
+		},
+	)
+
+	store := NewMemoryIdempotencyStore()
+	request := newIdempotentPayment(t)
+	record := newOperationRecord(OperationPayment, request)
+	ambiguousRecord(t, store, record)
+
+	_, err := NewClient(WithBaseURL(server.URL), WithRetryPolicy(nil), WithIdempotencyStore(store)).Payment(request)
+	if !errors.Is(err, ErrOperationOutcomeUnknown) || !errors.Is(err, ErrOperationInProgress) {
+		t.Fatalf("Payment error = %v, want the outcome unknown while the order is in progress", err)
+	}
+	if n := orders.Load(); n != 0 {
+		t.Errorf("createOrder called %d times for an order waiting for the customer", n)
+	}
+
+	existing, err := store.Begin(context.Background(), newOperationRecord(OperationPayment, request))
+	if err != nil {
+		t.Fatalf("Begin error = %v", err)
+	}
+	if existing == nil {
+		t.Error("the record of the pending payment was forgotten")
+	}
+}
+
+func TestRefundStaleRecordOfAnotherRefund(t *testing.T) {
+	var sent json.RawMessage
+
+	server := newStubServer(
+		t, map[string]http.HandlerFunc{
+			// refund 2 was made by another call, it is not the refund of the stale record
+			consts.CheckOrderStatePath: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "amount": 100.00, "paymentState": "Confirmed",
+					"paymentsList": [{"transactionId": 1, "amount": 100.00, "paymentState": "Confirmed"},
+					{"refundTransactionId": 2, "amount": 10.00, "paymentState": "Refunded"}]}`))
+			},
+			consts.CancelOrderPath: func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Amount json.RawMessage `json:"amount"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				sent = body.Amount
+
+				_, _ = w.Write([]byte(`{"orderId": "order-1", "transactionId": 1, "refundTransactionId": 3, "amount": 30.00, "paymentState": "accepted"}`))
+			},
+		},
+	)
+
+	amount := currency.New(3000, currency.UAH)
+	request, err := NewRefund(testMerchant).Order("order-1").Amount(amount).Build()
+	if err != nil {
+		t.Fatalf("Build error = %v", err)
+	}
+
+	store := NewMemoryIdempotencyStore()
+	record := newRefundRecord(request, amount, 0)
+	ambiguousRecord(t, store, record)
+
+	response, err := NewClient(WithBaseURL(server.URL), WithIdempotencyStore(store)).Refund(request)
+	if err != nil {
+		t.Fatalf("Refund error = %v", err)
+	}
+
+	if string(sent) != "30.00" {
+		t.Fatalf("cancelOrder amount = %s, want the new 30.00 refund to be sent", sent)
+	}
+
+	if response.RefundTransactionID != 3 {
+		t.Errorf("refund transaction = %d, want 3", response.RefundTransactionID)
+	}
+
+	assertRecordFinished(t, store, record)
+}
+
+func TestRefundRecordKey(t *testing.T) {
+	build := func(builder *RequestBuilder) *Request {
+		request, err := builder.Order("order-1").Build()
+		if err != nil {
+			t.Fatalf("Build error = %v", err)
+		}
+
+		return request
+	}
+
+	amount := currency.New(1000, currency.UAH)
+
+	tests := []struct {
+		name    string
+		request *Request
+		want    string
+	}{
+		{name: "remaining amount", request: build(NewRefund(testMerchant)), want: "refund:order-1:amount:0.00"},
+		{name: "partial amount", request: build(NewRefund(testMerchant).Amount(amount)), want: "refund:order-1:amount:10.00"},
+		{name: "refund ID", request: build(NewRefund(testMerchant).Amount(amount).RefundID("refund-1")), want: "refund:order-1:id:refund-1"},
+	}
+
+	for _, tt := range tests {
+		// the key does not change once the refund took effect, so a repeated refund finds its record
+		for _, refundCount := range []int{0, 1} {
+			if got := newRefundRecord(tt.request, amount, refundCount).Key; got != tt.want {
+				t.Errorf("%s: key with %d refunds = %s, want %s", tt.name, refundCount, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestResolveRefund(t *testing.T) {
+	state := &easypay.PaymentStatusResponse{
+		PaymentState: easypay.StatusConfirmed,
+		PaymentsList: []easypay.PaymentDetail{
+			{TransactionID: 1, Amount: currency.New(10000, ""), PaymentState: easypay.StatusConfirmed},
+			{RefundTransactionID: 2, Amount: currency.New(1000, ""), PaymentState: easypay.StatusRefunded},
+			{RefundTransactionID: 3, Amount: currency.New(3000, ""), PaymentState: easypay.StatusRefunded},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		record     *OperationRecord
+		wantRefund int64
+	}{
+		{name: "refund made after the record", record: &OperationRecord{RefundCount: 1, Amount: currency.New(3000, currency.UAH)}, wantRefund: 3},
+		{name: "refund of another amount", record: &OperationRecord{RefundCount: 1, Amount: currency.New(2000, currency.UAH)}},
+		{name: "same amount refunded before the record", record: &OperationRecord{RefundCount: 2, Amount: currency.New(1000, currency.UAH)}},
+		{name: "no refund since the record", record: &OperationRecord{RefundCount: 3, Amount: currency.New(3000, currency.UAH)}},
+	}
+
+	for _, tt := range tests {
+		refund := newRefund(state, tt.record)
+		if tt.wantRefund == 0 {
+			if refund != nil {
+				t.Errorf("%s: refund = %d, want none", tt.name, refund.RefundTransactionID)
+			}
+			continue
+		}
+
+		if refund == nil || refund.RefundTransactionID != tt.wantRefund {
+			t.Errorf("%s: refund = %v, want %d", tt.name, refund, tt.wantRefund)
+		}
+	}
+}
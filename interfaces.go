@@ -11,14 +11,29 @@ import (
 type Easypay interface {
 	VerificationLink(request *Request) (*url.URL, error)
 	Status(request *Request) (*easypay.Response, error)
-	PaymentURL(invoiceRequest *Request) (*easypay.Response, error)
+	OrderState(request *Request) (*easypay.PaymentStatusResponse, error)
+	PaymentURL(invoiceRequest *Request) (*easypay.PaymentURLResponse, error)
 	Payment(invoiceRequest *Request) (*easypay.Response, error)
 	Hold(invoiceRequest *Request) (*easypay.Response, error)
 	Capture(invoiceRequest *Request) (*easypay.Response, error)
-	Refund(invoiceRequest *Request) (*easypay.Response, error)
+	Refund(invoiceRequest *Request) (*easypay.CancelPaymentResponse, error)
+	VoidHold(request *Request) (*easypay.VoidHoldResponse, error)
 	Credit(invoiceRequest *Request) (*easypay.Response, error)
+	CreateRecurrent(request *Request) (*easypay.Response, error)
 	SetLogLevel(levelDebug log.Level)
 
+	VerificationLinkCtx(ctx context.Context, request *Request) (*url.URL, error)
+	StatusCtx(ctx context.Context, request *Request) (*easypay.Response, error)
+	OrderStateCtx(ctx context.Context, request *Request) (*easypay.PaymentStatusResponse, error)
+	PaymentURLCtx(ctx context.Context, invoiceRequest *Request) (*easypay.PaymentURLResponse, error)
+	PaymentCtx(ctx context.Context, invoiceRequest *Request) (*easypay.Response, error)
+	HoldCtx(ctx context.Context, invoiceRequest *Request) (*easypay.Response, error)
+	CaptureCtx(ctx context.Context, invoiceRequest *Request) (*easypay.Response, error)
+	RefundCtx(ctx context.Context, invoiceRequest *Request) (*easypay.CancelPaymentResponse, error)
+	VoidHoldCtx(ctx context.Context, request *Request) (*easypay.VoidHoldResponse, error)
+	CreditCtx(ctx context.Context, invoiceRequest *Request) (*easypay.Response, error)
+	CreateRecurrentCtx(ctx context.Context, request *Request) (*easypay.Response, error)
+
 	GetRecordedExchange(ctx context.Context, requestID string) (*easypay.RecordedExchange, error)
 	GetExchangesByOrderID(ctx context.Context, orderID string) ([]*easypay.RecordedExchange, error)
 	GetExchangesByTransactionID(ctx context.Context, transactionID string) ([]*easypay.RecordedExchange, error)
@@ -30,10 +30,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -44,6 +46,9 @@ import (
 	"github.com/stremovskyy/recorder"
 )
 
+// ErrNotSent marks failures that happened before the request left the client, so it cannot have taken effect
+var ErrNotSent = errors.New("request was not sent")
+
 type Client struct {
 	client         *http.Client
 	options        *Options
@@ -53,15 +58,68 @@ type Client struct {
 	recorder       recorder.Recorder
 }
 
-func (c *Client) Api(apiRequest *easypay.Request) (*easypay.Response, error) {
-	return c.sendRequest(apiRequest, c.logger, true)
+func (c *Client) Api(ctx context.Context, apiRequest *easypay.Request) (*easypay.Response, error) {
+	response := &easypay.Response{}
+
+	err := c.sendRequest(ctx, apiRequest, response, c.logger, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (c *Client) NotRecordedApi(ctx context.Context, apiRequest *easypay.Request) (*easypay.Response, error) {
+	response := &easypay.Response{}
+
+	err := c.sendRequest(ctx, apiRequest, response, c.logger, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
-func (c *Client) NotRecordedApi(apiRequest *easypay.Request) (*easypay.Response, error) {
-	return c.sendRequest(apiRequest, c.logger, false)
+// TypedApi sends the request and decodes the response into the given typed model
+func (c *Client) TypedApi(ctx context.Context, apiRequest *easypay.Request, response easypay.APIResponse) error {
+	return c.sendRequest(ctx, apiRequest, response, c.logger, true)
 }
 
-func (c *Client) sendRequest(apiRequest *easypay.Request, logger *log.Logger, record bool) (*easypay.Response, error) {
+func (c *Client) sendRequest(ctx context.Context, apiRequest *easypay.Request, response easypay.APIResponse, logger *log.Logger, record bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	apiRequest.Url = c.endpoint(apiRequest.Url)
+
+	policy := c.options.Retry
+	if !policy.allows(apiRequest) {
+		return c.sendOnce(ctx, apiRequest, response, logger, record)
+	}
+
+	for attempt := 1; ; attempt++ {
+		// a failed attempt may have partially decoded the response
+		response.Reset()
+
+		err := c.sendOnce(ctx, apiRequest, response, logger, record)
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryable(ctx, err) {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		logger.Warning("attempt %d of %s failed, retrying in %s: %v", attempt, apiRequest.Url, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, apiRequest *easypay.Request, response easypay.APIResponse, logger *log.Logger, record bool) error {
 	requestID := uuid.New().String()
 	logger.Debug("Request ID: %v", requestID)
 	logger.Debug("Request URL: %v", apiRequest.Url)
@@ -70,77 +128,97 @@ func (c *Client) sendRequest(apiRequest *easypay.Request, logger *log.Logger, re
 
 	jsonBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return c.logAndReturnError("cannot marshal request", err, logger, needToRecord, context.Background(), requestID, nil)
+		return c.logAndReturnError("cannot marshal request", fmt.Errorf("%w: %w", ErrNotSent, err), logger, needToRecord, context.WithoutCancel(ctx), requestID, nil)
 	}
+	// card data is masked in everything that is logged or recorded
+	redactedBody := redact(jsonBody)
 	if jsonBody != nil {
-		logger.Debug("Request: %v", string(jsonBody))
+		logger.Debug("Request: %v", string(redactedBody))
 	}
 
-	ctx := context.WithValue(context.Background(), "request_id", requestID)
+	ctx = context.WithValue(ctx, "request_id", requestID)
+	// recording must survive cancellation of the outbound call, so cancelled requests are still traced
+	recordCtx := context.WithoutCancel(ctx)
 	tags := tagsRetriever(apiRequest)
 
 	if needToRecord {
 		startTime := time.Now()
-		defer c.recordMetrics(ctx, requestID, startTime, tags, logger, apiRequest)
+		defer c.recordMetrics(recordCtx, requestID, startTime, tags, logger, apiRequest)
 	}
 
-	req, err := http.NewRequest("POST", apiRequest.Url, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiRequest.Url, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return c.logAndReturnError("cannot create request", err, logger, needToRecord, ctx, requestID, tags)
+		return c.logAndReturnError("cannot create request", fmt.Errorf("%w: %w", ErrNotSent, err), logger, needToRecord, recordCtx, requestID, tags)
 	}
 
-	signature := computeSignature(apiRequest.SecretKey, string(jsonBody))
+	signature := ComputeSignature(apiRequest.SecretKey, string(jsonBody))
 	c.setHeaders(req, requestID, apiRequest.Headers, signature)
 
 	if needToRecord {
-		err = c.recorder.RecordRequest(ctx, nil, requestID, jsonBody, tags)
+		err = c.recorder.RecordRequest(recordCtx, nil, requestID, redactedBody, tags)
 		if err != nil {
-			logger.Error("cannot record request", "error", err)
+			logger.Error("cannot record request: %v", err)
 		}
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return c.logAndReturnError("cannot send request", err, logger, needToRecord, ctx, requestID, tags)
+		return c.logAndReturnError("cannot send request", err, logger, needToRecord, recordCtx, requestID, tags)
 	}
 	defer resp.Body.Close()
 
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.logAndReturnError("cannot read response", err, logger, needToRecord, ctx, requestID, tags)
+		return c.logAndReturnError("cannot read response", &transientError{err: err}, logger, needToRecord, recordCtx, requestID, tags)
 	}
 
-	logger.Debug("Response: %v", string(raw))
+	redactedRaw := redact(raw)
+	logger.Debug("Response: %v", string(redactedRaw))
 	logger.Debug("Response status: %v", resp.StatusCode)
 
 	if needToRecord {
-		err = c.recorder.RecordResponse(ctx, nil, requestID, raw, tags)
+		err = c.recorder.RecordResponse(recordCtx, nil, requestID, redactedRaw, tags)
 		if err != nil {
-			logger.Error("cannot record response", "error", err)
+			logger.Error("cannot record response: %v", err)
 		}
 	}
 
-	response, err := easypay.UnmarshalJSONResponse(raw)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		httpError := easypay.NewHTTPError(resp.StatusCode, resp.Header, raw, requestID)
+
+		// API rejections may come with an error status, their body still describes the error
+		if json.Unmarshal(raw, response) == nil && response.GetError() != nil {
+			if apiRequest.SkipGeneratingError {
+				return nil
+			}
+
+			httpError.Err = c.ResponseError(response)
+		}
+
+		return c.logAndReturnError("unexpected response status", httpError, logger, needToRecord, recordCtx, requestID, tags)
+	}
+
+	err = json.Unmarshal(raw, response)
 	if err != nil {
-		return c.logAndReturnError("cannot unmarshal response", err, logger, needToRecord, ctx, requestID, tags)
+		return c.logAndReturnError("cannot unmarshal response", fmt.Errorf("error unmarshalling JSON response: %w", err), logger, needToRecord, recordCtx, requestID, tags)
 	}
 
 	if !apiRequest.SkipGeneratingError && response.GetError() != nil {
-		return nil, fmt.Errorf("easypay error: %v", response.GetError())
+		return fmt.Errorf("easypay error: %w", c.ResponseError(response))
 	}
 
-	return response, nil
+	return nil
 }
 
-func (c *Client) logAndReturnError(msg string, err error, logger *log.Logger, needToRecord bool, ctx context.Context, requestID string, tags map[string]string) (*easypay.Response, error) {
-	logger.Error(msg, "error", err)
+func (c *Client) logAndReturnError(msg string, err error, logger *log.Logger, needToRecord bool, ctx context.Context, requestID string, tags map[string]string) error {
+	logger.Error("%s: %v", msg, err)
 	if needToRecord && c.recorder != nil {
 		recordErr := c.recorder.RecordError(ctx, nil, requestID, err, tags)
 		if recordErr != nil {
-			logger.Error("cannot record error", "error", recordErr)
+			logger.Error("cannot record error: %v", recordErr)
 		}
 	}
-	return nil, err
+	return err
 }
 
 func (c *Client) recordMetrics(ctx context.Context, requestID string, startTime time.Time, tags map[string]string, logger *log.Logger, request *easypay.Request) {
@@ -152,7 +230,7 @@ func (c *Client) recordMetrics(ctx context.Context, requestID string, startTime
 
 	err := c.recorder.RecordMetrics(ctx, nil, requestID, metricsMap, tags)
 	if err != nil {
-		logger.Error("cannot record metrics", "error", err)
+		logger.Error("cannot record metrics: %v", err)
 	}
 }
 
@@ -177,7 +255,7 @@ func (c *Client) setHeaders(req *http.Request, requestID string, headers map[str
 	req.Header.Set("locale", "ua")
 	req.Header.Set("User-Agent", "GO EASYPAY/"+consts.Version)
 	req.Header.Set("X-Request-ID", requestID)
-	req.Header.Set("Api-Version", consts.ApiVersion)
+	req.Header.Set("Api-Version", c.apiVersion())
 	req.Header.Set("Sign", signature)
 
 	if headers != nil {
@@ -202,6 +280,53 @@ func (c *Client) SetRecorder(r recorder.Recorder) {
 	c.recorder = r
 }
 
+// endpoint resolves an endpoint path against the base URL, absolute URLs are kept as is
+func (c *Client) endpoint(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+
+	baseURL := c.options.BaseURL
+	if baseURL == "" {
+		baseURL = consts.BaseURL
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func (c *Client) apiVersion() string {
+	if c.options.ApiVersion == "" {
+		return consts.ApiVersion
+	}
+
+	return c.options.ApiVersion
+}
+
+// SetBaseURL changes the base URL of all endpoints
+func (c *Client) SetBaseURL(baseURL string) {
+	c.options.BaseURL = baseURL
+}
+
+// SetApiVersion changes the Api-Version header sent with every request
+func (c *Client) SetApiVersion(version string) {
+	c.options.ApiVersion = version
+}
+
+// SetErrorCodes maps error codes on top of the built-in ones, see easypay.ErrorCodes
+func (c *Client) SetErrorCodes(codes easypay.ErrorCodes) {
+	c.options.ErrorCodes = codes
+}
+
+// ResponseError returns the error of the response, matched with errors.Is by the error codes of the client
+func (c *Client) ResponseError(response easypay.APIResponse) error {
+	return c.options.ErrorCodes.Apply(response.GetError())
+}
+
+// SetRetryPolicy replaces the retry policy, nil disables retries
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.options.Retry = policy
+}
+
 func (c *Client) GetRecorder() recorder.Recorder {
 	return c.recorder
 }
@@ -235,7 +360,8 @@ func NewClient(options *Options) *Client {
 	}
 }
 
-func computeSignature(secretKey, requestBody string) string {
+// ComputeSignature returns the base64 encoded SHA256 of the secret key followed by the body, as used in the Sign header
+func ComputeSignature(secretKey, requestBody string) string {
 	// Concatenate the secret key and request body
 	data := secretKey + requestBody
 
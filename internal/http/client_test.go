@@ -0,0 +1,149 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+func TestApiHTTPError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantBody    string
+		wantCode    string
+		wantErr     error
+		wantTemp    bool
+		wantMessage string
+	}{
+		{
+			name:        "non-JSON body",
+			status:      http.StatusBadGateway,
+			body:        "<html>502 Bad Gateway</html>",
+			wantBody:    "<html>502 Bad Gateway</html>",
+			wantTemp:    true,
+			wantMessage: "http status 502 (request gateway-1): <html>502 Bad Gateway</html>",
+		},
+		{
+			name:     "JSON body without an error",
+			status:   http.StatusServiceUnavailable,
+			body:     `{"paymentState": "Pending"}`,
+			wantBody: `{"paymentState": "Pending"}`,
+			wantTemp: true,
+		},
+		{
+			name:     "truncated body",
+			status:   http.StatusInternalServerError,
+			body:     strings.Repeat("x", 2000),
+			wantBody: strings.Repeat("x", 1024),
+			wantTemp: true,
+		},
+		{
+			name:     "API error",
+			status:   http.StatusBadRequest,
+			body:     `{"error": {"errorCode": "INVALID_SIGNATURE", "title": "invalid signature"}}`,
+			wantBody: `{"error": {"errorCode": "INVALID_SIGNATURE", "title": "invalid signature"}}`,
+			wantCode: easypay.CodeInvalidSignature,
+			wantErr:  easypay.ErrInvalidSignature,
+		},
+		{
+			name:     "temporary API error",
+			status:   http.StatusInternalServerError,
+			body:     `{"error": {"errorCode": "INTERNAL_ERROR"}}`,
+			wantBody: `{"error": {"errorCode": "INTERNAL_ERROR"}}`,
+			wantCode: easypay.CodeInternalError,
+			wantErr:  easypay.ErrTemporary,
+			wantTemp: true,
+		},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Request-ID", "gateway-1")
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				},
+			),
+		)
+
+		c := NewClient(DefaultOptions())
+		response, err := c.Api(context.Background(), &easypay.Request{Url: server.URL, Operation: "payment"})
+		server.Close()
+
+		if response != nil {
+			t.Errorf("%s: Api() response = %+v, want nil", tt.name, response)
+		}
+
+		var httpError *easypay.HTTPError
+		if !errors.As(err, &httpError) {
+			t.Errorf("%s: Api() error = %v, want an HTTPError", tt.name, err)
+			continue
+		}
+
+		if httpError.StatusCode != tt.status || httpError.Body != tt.wantBody || httpError.RequestID != "gateway-1" {
+			t.Errorf("%s: HTTPError = %d %q %q, want %d %q gateway-1", tt.name, httpError.StatusCode, httpError.Body, httpError.RequestID, tt.status, tt.wantBody)
+		}
+
+		if httpError.Temporary() != tt.wantTemp {
+			t.Errorf("%s: Temporary() = %v, want %v", tt.name, httpError.Temporary(), tt.wantTemp)
+		}
+
+		if tt.wantMessage != "" && httpError.Error() != tt.wantMessage {
+			t.Errorf("%s: Error() = %q, want %q", tt.name, httpError.Error(), tt.wantMessage)
+		}
+
+		customError, isAPIError := easypay.AsAPIError(err)
+
+		if tt.wantCode == "" {
+			if isAPIError || httpError.IsAPIError() || httpError.Unwrap() != nil {
+				t.Errorf("%s: HTTPError wraps %v, want no API error", tt.name, httpError.Unwrap())
+			}
+			continue
+		}
+
+		if !isAPIError || !httpError.IsAPIError() || customError.Code() != tt.wantCode {
+			t.Errorf("%s: Api() error = %v, want API error %s", tt.name, err, tt.wantCode)
+			continue
+		}
+
+		if unwrapped := httpError.Unwrap(); unwrapped != error(customError) {
+			t.Errorf("%s: Unwrap() = %v, want the API error %v", tt.name, unwrapped, customError)
+		}
+
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Api() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -24,7 +24,12 @@
 
 package http
 
-import "time"
+import (
+	"time"
+
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
 
 type Options struct {
 	Timeout         time.Duration
@@ -32,6 +37,12 @@ type Options struct {
 	MaxIdleConns    int
 	IdleConnTimeout time.Duration
 	IsDebug         bool
+	Retry           *RetryPolicy
+	// BaseURL is prepended to endpoint paths, set it to target a sandbox or a local fake
+	BaseURL    string
+	ApiVersion string
+	// ErrorCodes maps error codes on top of the built-in ones, see easypay.ErrorCodes
+	ErrorCodes easypay.ErrorCodes
 }
 
 func DefaultOptions() *Options {
@@ -41,5 +52,8 @@ func DefaultOptions() *Options {
 		MaxIdleConns:    100,
 		IdleConnTimeout: 90 * time.Second,
 		IsDebug:         false,
+		Retry:           DefaultRetryPolicy(),
+		BaseURL:         consts.BaseURL,
+		ApiVersion:      consts.ApiVersion,
 	}
 }
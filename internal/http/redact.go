@@ -0,0 +1,101 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// sensitiveFields are masked at any depth of logged and recorded payloads. Keys are matched case-insensitively
+// by their last dot separated part, so the Card.Pan of response items is masked too. PANs keep their first 6
+// and last 4 digits.
+var sensitiveFields = map[string]func(string) string{
+	"pan": maskPan,
+	"cvv": func(string) string { return "***" },
+}
+
+// redact returns the payload with card data masked, the payload sent to Easypay is left untouched.
+// Payloads that are not JSON objects or arrays are returned as they are.
+func redact(payload []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return payload
+	}
+
+	if !redactValue(value) {
+		return payload
+	}
+
+	masked, err := json.Marshal(value)
+	if err != nil {
+		return payload
+	}
+
+	return masked
+}
+
+// redactValue masks sensitive fields in place and reports whether anything was masked
+func redactValue(value any) bool {
+	masked := false
+
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if mask, ok := sensitiveFields[fieldName(key)]; ok {
+				if s, isString := field.(string); isString && s != "" {
+					v[key] = mask(s)
+					masked = true
+					continue
+				}
+			}
+
+			masked = redactValue(field) || masked
+		}
+	case []any:
+		for _, item := range v {
+			masked = redactValue(item) || masked
+		}
+	}
+
+	return masked
+}
+
+// fieldName returns the lower case last part of a dotted key such as Card.Pan
+func fieldName(key string) string {
+	return strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+}
+
+func maskPan(pan string) string {
+	if len(pan) <= 10 {
+		return strings.Repeat("*", len(pan))
+	}
+
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
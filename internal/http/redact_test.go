@@ -0,0 +1,95 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{
+			name:    "recipient pan",
+			payload: `{"orderId":"order-1","recipient":{"pan":"4111111111111111"}}`,
+			want:    `{"orderId":"order-1","recipient":{"pan":"411111******1111"}}`,
+		},
+		{
+			name:    "response items card pan and cvv",
+			payload: `{"responseItems":{"Card.Pan":"5168742060221234","Card.Guid":"guid"},"cvv":"123"}`,
+			want:    `{"cvv":"***","responseItems":{"Card.Guid":"guid","Card.Pan":"516874******1234"}}`,
+		},
+		{
+			name:    "nested card pan and cvv",
+			payload: `{"card":{"CVV":"123","Pan":"5168742060221234"}}`,
+			want:    `{"card":{"CVV":"***","Pan":"516874******1234"}}`,
+		},
+		{
+			name:    "pan as part of another key",
+			payload: `{"panHash":"4111111111111111","Card.PanMasked":"411111******1111"}`,
+			want:    `{"panHash":"4111111111111111","Card.PanMasked":"411111******1111"}`,
+		},
+		{
+			name:    "pan in array",
+			payload: `[{"pan":"4111111111111111"}]`,
+			want:    `[{"pan":"411111******1111"}]`,
+		},
+		{
+			name:    "short pan",
+			payload: `{"pan":"411111"}`,
+			want:    `{"pan":"******"}`,
+		},
+		{
+			name:    "no card data",
+			payload: `{"amount": 10.50, "orderId":"order-1"}`,
+			want:    `{"amount": 10.50, "orderId":"order-1"}`,
+		},
+		{
+			name:    "empty pan",
+			payload: `{"pan":""}`,
+			want:    `{"pan":""}`,
+		},
+		{
+			name:    "not json",
+			payload: `pan=4111111111111111`,
+			want:    `pan=4111111111111111`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := string(redact([]byte(tt.payload))); got != tt.want {
+			t.Errorf("%s: redact() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedactKeepsNumbers(t *testing.T) {
+	got := string(redact([]byte(`{"amount":10.10,"pan":"4111111111111111"}`)))
+	want := `{"amount":10.10,"pan":"411111******1111"}`
+	if got != want {
+		t.Fatalf("redact() = %s, want %s", got, want)
+	}
+}
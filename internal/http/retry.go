@@ -0,0 +1,147 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// RetryPolicy configures automatic retries of failed requests.
+// Requests are retried only when they are marked safe to resend, money moving requests additionally
+// need RetryMoneyMoving, or their operation enabled in Operations, and an idempotency key that lets
+// Easypay reject the duplicate.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt
+	Multiplier float64
+	// Jitter is the fraction of the delay that is randomised, between 0 and 1
+	Jitter float64
+	// RetryMoneyMoving enables retries of requests carrying an idempotency key
+	RetryMoneyMoving bool
+	// Operations enables (true) or disables (false) retries of single operations, operations that are
+	// not listed follow the rules above. Enabling a money moving operation still needs an idempotency key.
+	Operations map[easypay.Operation]bool
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// transientError marks transport failures that may succeed when retried
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// allows reports whether the request may be sent again under the policy
+func (p *RetryPolicy) allows(apiRequest *easypay.Request) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+
+	enabled, listed := p.Operations[apiRequest.Operation]
+	if listed && !enabled {
+		return false
+	}
+
+	if apiRequest.Retryable {
+		return true
+	}
+
+	// a money moving request is resent only when Easypay rejects the duplicate
+	if apiRequest.IdempotencyKey == "" {
+		return false
+	}
+
+	return p.RetryMoneyMoving || enabled
+}
+
+// backoff returns the delay before the given retry, starting from 1
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// isRetryable separates transient failures from terminal ones such as card declines
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if httpError, ok := easypay.AsHTTPError(err); ok {
+		return httpError.Temporary()
+	}
+
+	if errors.Is(err, easypay.ErrTemporary) {
+		return true
+	}
+
+	var transient *transientError
+	if errors.As(err, &transient) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
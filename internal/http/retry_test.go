@@ -0,0 +1,356 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+func TestRetryPolicyAllows(t *testing.T) {
+	safe := &easypay.Request{Operation: "status", Retryable: true}
+	payment := &easypay.Request{Operation: "payment", IdempotencyKey: "order-1"}
+	credit := &easypay.Request{Operation: "credit"}
+
+	tests := []struct {
+		name    string
+		policy  *RetryPolicy
+		request *easypay.Request
+		want    bool
+	}{
+		{name: "nil policy", policy: nil, request: safe, want: false},
+		{name: "single attempt", policy: &RetryPolicy{MaxAttempts: 1}, request: safe, want: false},
+		{name: "safe request", policy: DefaultRetryPolicy(), request: safe, want: true},
+		{name: "safe operation disabled", policy: withOperations(DefaultRetryPolicy(), map[easypay.Operation]bool{"status": false}), request: safe, want: false},
+		{name: "money moving by default", policy: DefaultRetryPolicy(), request: payment, want: false},
+		{name: "money moving enabled", policy: withMoneyMoving(DefaultRetryPolicy()), request: payment, want: true},
+		{name: "money moving operation enabled", policy: withOperations(DefaultRetryPolicy(), map[easypay.Operation]bool{"payment": true}), request: payment, want: true},
+		{name: "money moving operation disabled", policy: withOperations(withMoneyMoving(DefaultRetryPolicy()), map[easypay.Operation]bool{"payment": false}), request: payment, want: false},
+		{name: "no idempotency key", policy: withOperations(withMoneyMoving(DefaultRetryPolicy()), map[easypay.Operation]bool{"credit": true}), request: credit, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.allows(tt.request); got != tt.want {
+			t.Errorf("%s: allows() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func withMoneyMoving(policy *RetryPolicy) *RetryPolicy {
+	policy.RetryMoneyMoving = true
+
+	return policy
+}
+
+func withOperations(policy *RetryPolicy, operations map[easypay.Operation]bool) *RetryPolicy {
+	policy.Operations = operations
+
+	return policy
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *RetryPolicy
+		retry    int
+		min, max time.Duration
+	}{
+		{name: "first retry", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2}, retry: 1, min: 100 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "grows by multiplier", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2}, retry: 3, min: 400 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}, retry: 10, min: time.Second, max: time.Second},
+		{name: "multiplier below one", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 0.5}, retry: 4, min: 100 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "jitter", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.2}, retry: 2, min: 160 * time.Millisecond, max: 240 * time.Millisecond},
+		{name: "jitter of capped delay", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2, Jitter: 0.5}, retry: 10, min: 500 * time.Millisecond, max: 1500 * time.Millisecond},
+		{name: "jitter above one", policy: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, Jitter: 3}, retry: 1, min: 0, max: 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		delays := make(map[time.Duration]bool)
+
+		for i := 0; i < 200; i++ {
+			delay := tt.policy.backoff(tt.retry)
+			if delay < tt.min || delay > tt.max {
+				t.Errorf("%s: backoff(%d) = %s, want between %s and %s", tt.name, tt.retry, delay, tt.min, tt.max)
+				break
+			}
+			delays[delay] = true
+		}
+
+		// jitter spreads the retries of concurrent clients
+		if tt.policy.Jitter > 0 && len(delays) < 2 {
+			t.Errorf("%s: backoff(%d) returned the same delay every time", tt.name, tt.retry)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	apiError := func(statusCode int, err error) error {
+		httpError := easypay.NewHTTPError(statusCode, http.Header{}, nil, "request-1")
+		httpError.Err = err
+
+		return fmt.Errorf("unexpected response status: %w", httpError)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "internal server error", ctx: context.Background(), err: apiError(http.StatusInternalServerError, nil), want: true},
+		{name: "bad gateway", ctx: context.Background(), err: apiError(http.StatusBadGateway, nil), want: true},
+		{name: "service unavailable", ctx: context.Background(), err: apiError(http.StatusServiceUnavailable, nil), want: true},
+		{name: "too many requests", ctx: context.Background(), err: apiError(http.StatusTooManyRequests, nil), want: true},
+		{name: "request timeout", ctx: context.Background(), err: apiError(http.StatusRequestTimeout, nil), want: true},
+		{name: "bad request", ctx: context.Background(), err: apiError(http.StatusBadRequest, nil), want: false},
+		{name: "not found", ctx: context.Background(), err: apiError(http.StatusNotFound, nil), want: false},
+		{name: "conflict", ctx: context.Background(), err: apiError(http.StatusConflict, nil), want: false},
+		{name: "temporary api error", ctx: context.Background(), err: apiError(http.StatusBadRequest, easypay.ErrTemporary), want: true},
+		{name: "card declined with server error status", ctx: context.Background(), err: apiError(http.StatusInternalServerError, errors.New("card declined")), want: false},
+		{name: "temporary error", ctx: context.Background(), err: fmt.Errorf("easypay error: %w", easypay.ErrTemporary), want: true},
+		{name: "transport error", ctx: context.Background(), err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: true},
+		{name: "truncated response", ctx: context.Background(), err: &transientError{err: errors.New("unexpected EOF")}, want: true},
+		{name: "terminal error", ctx: context.Background(), err: errors.New("cannot marshal request"), want: false},
+		{name: "cancelled", ctx: context.Background(), err: context.Canceled, want: false},
+		{name: "context cancelled", ctx: cancelled, err: apiError(http.StatusServiceUnavailable, nil), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryable(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSendRequestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *easypay.Request
+		statuses     []int
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{name: "succeeds first time", request: &easypay.Request{Operation: "status", Retryable: true}, statuses: []int{200}, wantAttempts: 1},
+		{name: "recovers from server error", request: &easypay.Request{Operation: "status", Retryable: true}, statuses: []int{503, 502, 200}, wantAttempts: 3},
+		{name: "recovers from rate limit", request: &easypay.Request{Operation: "status", Retryable: true}, statuses: []int{429, 200}, wantAttempts: 2},
+		{name: "gives up after max attempts", request: &easypay.Request{Operation: "status", Retryable: true}, statuses: []int{500, 500, 500, 200}, wantAttempts: 3, wantStatus: 500},
+		{name: "client error is not retried", request: &easypay.Request{Operation: "status", Retryable: true}, statuses: []int{400, 200}, wantAttempts: 1, wantStatus: 400},
+		{name: "money moving without idempotency key", request: &easypay.Request{Operation: "payment"}, statuses: []int{503, 200}, wantAttempts: 1, wantStatus: 503},
+	}
+
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					attempt := attempts.Add(1)
+					w.WriteHeader(tt.statuses[attempt-1])
+					_, _ = w.Write([]byte(`{}`))
+				},
+			),
+		)
+
+		c := newRetryingClient()
+		tt.request.Url = server.URL
+
+		err := c.sendRequest(context.Background(), tt.request, &easypay.Response{}, c.logger, false)
+		server.Close()
+
+		if got := attempts.Load(); got != tt.wantAttempts {
+			t.Errorf("%s: attempts = %d, want %d", tt.name, got, tt.wantAttempts)
+		}
+
+		if tt.wantStatus == 0 {
+			if err != nil {
+				t.Errorf("%s: error = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+
+		if httpError, ok := easypay.AsHTTPError(err); !ok || httpError.StatusCode != tt.wantStatus {
+			t.Errorf("%s: error = %v, want http status %d", tt.name, err, tt.wantStatus)
+		}
+	}
+}
+
+func TestSendRequestResetsResponseBetweenAttempts(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = w.Write([]byte(`{"error": {"errorCode": "INTERNAL_ERROR"}, "forwardUrl": "https://failed"}`))
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"paymentState": "Confirmed"}`))
+			},
+		),
+	)
+	defer server.Close()
+
+	c := newRetryingClient()
+	response := &easypay.Response{}
+
+	request := &easypay.Request{Url: server.URL, Operation: "status", Retryable: true}
+	if err := c.sendRequest(context.Background(), request, response, c.logger, false); err != nil {
+		t.Fatalf("sendRequest() error = %v", err)
+	}
+
+	if response.Error != nil || response.ForwardUrl != "" || response.PaymentState != easypay.StatusConfirmed {
+		t.Errorf("response = %+v, want only the successful attempt decoded", response)
+	}
+}
+
+func TestSendRequestRetriesErrorCodesOfTheClient(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		codes        easypay.ErrorCodes
+		wantAttempts int32
+	}{
+		{name: "unknown code", wantAttempts: 1},
+		{name: "code mapped to ErrTemporary", codes: easypay.NewErrorCodes(map[string]error{"TRY_AGAIN_LATER": easypay.ErrTemporary}), wantAttempts: 2},
+	} {
+		var attempts atomic.Int32
+		server := httptest.NewServer(
+			http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					if attempts.Add(1) == 1 {
+						_, _ = w.Write([]byte(`{"error": {"errorCode": "TRY_AGAIN_LATER"}}`))
+						return
+					}
+
+					_, _ = w.Write([]byte(`{"paymentState": "Confirmed"}`))
+				},
+			),
+		)
+
+		c := newRetryingClient()
+		c.SetErrorCodes(tt.codes)
+
+		request := &easypay.Request{Url: server.URL, Operation: "status", Retryable: true}
+		err := c.sendRequest(context.Background(), request, &easypay.Response{}, c.logger, false)
+		server.Close()
+
+		if got := attempts.Load(); got != tt.wantAttempts {
+			t.Errorf("%s: attempts = %d, want %d", tt.name, got, tt.wantAttempts)
+		}
+		if (err == nil) != (tt.wantAttempts == 2) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+// roundTripFunc lets a test fail requests at the transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSendRequestRetriesTransportErrors(t *testing.T) {
+	var attempts atomic.Int32
+
+	c := newRetryingClient()
+	c.SetClient(
+		&http.Client{
+			Transport: roundTripFunc(
+				func(r *http.Request) (*http.Response, error) {
+					attempts.Add(1)
+					return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+				},
+			),
+		},
+	)
+
+	request := &easypay.Request{Url: "http://easypay.test/api", Operation: "status", Retryable: true}
+	if err := c.sendRequest(context.Background(), request, &easypay.Response{}, c.logger, false); err == nil {
+		t.Fatal("sendRequest() error = nil, want the transport error")
+	}
+
+	if got := attempts.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+}
+
+func TestSendRequestStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				// the caller gives up while the client waits to retry
+				time.AfterFunc(50*time.Millisecond, cancel)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		),
+	)
+	defer server.Close()
+
+	c := newRetryingClient()
+	c.SetRetryPolicy(&RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, Multiplier: 1})
+
+	done := make(chan error, 1)
+	go func() {
+		request := &easypay.Request{Url: server.URL, Operation: "status", Retryable: true}
+		done <- c.sendRequest(ctx, request, &easypay.Response{}, c.logger, false)
+	}()
+
+	select {
+	case err := <-done:
+		if httpError, ok := easypay.AsHTTPError(err); !ok || httpError.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("sendRequest() error = %v, want the last failure", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sendRequest() kept waiting after the context was cancelled")
+	}
+
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("attempts = %d, want 1", got)
+	}
+}
+
+func newRetryingClient() *Client {
+	c := NewClient(DefaultOptions())
+	c.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Multiplier: 2, Jitter: 0.2})
+
+	return c
+}
@@ -27,3 +27,14 @@ package utils
 func Ref[T any](value T) *T {
 	return &value
 }
+
+// Clone returns a pointer to a copy of the value, nil for nil
+func Clone[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+
+	clone := *value
+
+	return &clone
+}
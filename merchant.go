@@ -26,6 +26,8 @@ package go_easypay
 
 import (
 	"strconv"
+
+	"github.com/stremovskyy/go-easypay/currency"
 )
 
 type Merchant struct {
@@ -37,6 +39,8 @@ type Merchant struct {
 	ServiceKey string
 	// System Key
 	SecretKey string
+	// PreviousSecretKeys are still accepted when verifying webhooks while the System Key is rotated
+	PreviousSecretKeys []string
 
 	// SuccessRedirect
 	SuccessRedirect string
@@ -51,6 +55,9 @@ type Merchant struct {
 	PayerName        string
 	AppleMerchantID  *string
 	GoogleMerchantID *string
+
+	// Currencies supported by the service key, any known currency is accepted when empty
+	Currencies []currency.Code
 }
 
 func (m *Merchant) GetMerchantID() *int64 {
@@ -74,3 +81,19 @@ func (m *Merchant) GetSecretKey() string {
 func (m *Merchant) GetServiceKey() string {
 	return m.ServiceKey
 }
+
+// SupportsCurrency reports whether the merchant service key accepts payments in the currency,
+// a merchant without Currencies is not restricted
+func (m *Merchant) SupportsCurrency(code currency.Code) bool {
+	if len(m.Currencies) == 0 {
+		return true
+	}
+
+	for _, supported := range m.Currencies {
+		if supported == code {
+			return true
+		}
+	}
+
+	return false
+}
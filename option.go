@@ -27,9 +27,19 @@ package go_easypay
 import (
 	"net/http"
 
+	"github.com/stremovskyy/go-easypay/easypay"
+	easypayhttp "github.com/stremovskyy/go-easypay/internal/http"
 	"github.com/stremovskyy/recorder"
 )
 
+// RetryPolicy configures automatic retries, see WithRetryPolicy
+type RetryPolicy = easypayhttp.RetryPolicy
+
+// DefaultRetryPolicy retries safe requests up to 3 times with exponential backoff
+func DefaultRetryPolicy() *RetryPolicy {
+	return easypayhttp.DefaultRetryPolicy()
+}
+
 type Option func(*client)
 
 func WithClient(cl *http.Client) Option {
@@ -43,3 +53,40 @@ func WithRecorder(r recorder.Recorder) Option {
 		c.easypayClient.SetRecorder(r)
 	}
 }
+
+// WithRetryPolicy replaces the default retry policy, nil disables retries
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *client) {
+		c.easypayClient.SetRetryPolicy(policy)
+	}
+}
+
+// WithIdempotencyStore protects Payment, Hold, Capture and Refund against double execution,
+// in-flight operations are kept in the store and resolved through the order state
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(c *client) {
+		c.idempotency = store
+	}
+}
+
+// WithBaseURL points the client to another Easypay environment, such as a sandbox or an httptest.Server
+func WithBaseURL(baseURL string) Option {
+	return func(c *client) {
+		c.easypayClient.SetBaseURL(baseURL)
+	}
+}
+
+// WithErrorCodes maps Easypay error codes to the known errors of the easypay package for this client,
+// on top of the built-in codes. A code mapped to nil is no longer known.
+func WithErrorCodes(codes map[string]error) Option {
+	return func(c *client) {
+		c.easypayClient.SetErrorCodes(easypay.NewErrorCodes(codes))
+	}
+}
+
+// WithAPIVersion overrides the Api-Version header sent with every request
+func WithAPIVersion(version string) Option {
+	return func(c *client) {
+		c.easypayClient.SetApiVersion(version)
+	}
+}
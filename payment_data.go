@@ -29,10 +29,13 @@ import "github.com/stremovskyy/go-easypay/currency"
 type PaymentData struct {
 	EasypayPaymentID *int64
 	PaymentID        *string
-	Amount           float64
+	Amount           currency.Money
 	Currency         currency.Code
 	OrderID          string
 	Description      string
 	WebhookURL       *string
 	IsMobile         bool
+	// RefundID identifies a refund of the order, a refund repeated with the same ID is sent at most once.
+	// Refunds without an ID are told apart by their amount.
+	RefundID *string
 }
@@ -33,4 +33,5 @@ type PaymentMethod struct {
 type Card struct {
 	Name  string
 	Token *string
+	Pan   *string
 }
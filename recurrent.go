@@ -0,0 +1,89 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+type Recurrent struct {
+	// CronRule is the schedule of the charges
+	CronRule easypay.CronRule
+	// DateRun is the first charge date, defaults to now
+	DateRun *time.Time
+	// DateExpire is the date after which no more charges are made
+	DateExpire time.Time
+	// FailedCount is the number of retries of a failed charge
+	FailedCount int
+	// FailedRule is the schedule of the retries of a failed charge
+	FailedRule easypay.CronRule
+	// Amount of every charge, defaults to the payment amount
+	Amount currency.Money
+	// NotifyURL receives the notifications of every charge, defaults to the payment webhook
+	NotifyURL *string
+}
+
+// Validate checks the schedule of the recurrent payment, all problems are reported together and
+// are matched with errors.Is by their sentinel errors, invalid rules by easypay.ErrInvalidCronRule
+func (r *Recurrent) Validate() error {
+	var errs []error
+
+	errs = append(errs, r.CronRule.Validate())
+
+	if r.FailedCount < 0 {
+		errs = append(errs, ErrFailedCountIsNegative)
+	}
+
+	if r.FailedCount > 0 {
+		if err := r.FailedRule.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("failed rule: %w", err))
+		}
+	}
+
+	if r.DateExpire.IsZero() {
+		errs = append(errs, ErrExpireDateIsZero)
+	} else if !r.DateExpire.After(r.dateRun()) {
+		errs = append(errs, ErrExpireDateBeforeRun)
+	}
+
+	if r.Amount.IsNegative() {
+		errs = append(errs, ErrAmountIsNegative)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Recurrent) dateRun() time.Time {
+	if r.DateRun == nil {
+		return time.Now()
+	}
+
+	return *r.DateRun
+}
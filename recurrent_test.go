@@ -0,0 +1,136 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+func TestRecurrentValidate(t *testing.T) {
+	run := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	valid := func() *Recurrent {
+		return &Recurrent{
+			CronRule:   easypay.Monthly(1, 10, 0),
+			DateRun:    &run,
+			DateExpire: run.AddDate(1, 0, 0),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *Recurrent)
+		want   []error
+	}{
+		{name: "valid", modify: func(r *Recurrent) {}},
+		{name: "retries", modify: func(r *Recurrent) { r.FailedCount = 3; r.FailedRule = easypay.Daily(12, 0) }},
+		{name: "failed rule is ignored without retries", modify: func(r *Recurrent) { r.FailedRule = "bad" }},
+		{name: "invalid rule", modify: func(r *Recurrent) { r.CronRule = "0 25 * * *" }, want: []error{easypay.ErrInvalidCronRule}},
+		{name: "missing rule", modify: func(r *Recurrent) { r.CronRule = "" }, want: []error{easypay.ErrInvalidCronRule}},
+		{name: "negative failed count", modify: func(r *Recurrent) { r.FailedCount = -1 }, want: []error{ErrFailedCountIsNegative}},
+		{name: "invalid failed rule", modify: func(r *Recurrent) { r.FailedCount = 2 }, want: []error{easypay.ErrInvalidCronRule}},
+		{name: "missing expire date", modify: func(r *Recurrent) { r.DateExpire = time.Time{} }, want: []error{ErrExpireDateIsZero}},
+		{name: "expire date before run", modify: func(r *Recurrent) { r.DateExpire = run.Add(-time.Hour) }, want: []error{ErrExpireDateBeforeRun}},
+		{name: "expire date at run", modify: func(r *Recurrent) { r.DateExpire = run }, want: []error{ErrExpireDateBeforeRun}},
+		{name: "negative amount", modify: func(r *Recurrent) { r.Amount = currency.New(-100, currency.UAH) }, want: []error{ErrAmountIsNegative}},
+		{
+			name: "every problem",
+			modify: func(r *Recurrent) {
+				r.CronRule = "bad"
+				r.FailedCount = -1
+				r.DateExpire = time.Time{}
+				r.Amount = currency.New(-1, currency.UAH)
+			},
+			want: []error{easypay.ErrInvalidCronRule, ErrFailedCountIsNegative, ErrExpireDateIsZero, ErrAmountIsNegative},
+		},
+	}
+
+	for _, tt := range tests {
+		r := valid()
+		tt.modify(r)
+
+		err := r.Validate()
+		if (err != nil) != (len(tt.want) > 0) {
+			t.Errorf("%s: Validate() error = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: Validate() error = %v, want it to match %v", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestRequestGetRecurrent(t *testing.T) {
+	run := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	request := &Request{
+		PaymentData: &PaymentData{Amount: currency.New(9900, currency.UAH), WebhookURL: utils.Ref("https://example.com/webhook")},
+		Recurrent: &Recurrent{
+			CronRule:    easypay.Monthly(1, 10, 0),
+			DateRun:     &run,
+			DateExpire:  run.AddDate(1, 0, 0),
+			FailedCount: 2,
+			FailedRule:  easypay.Daily(12, 0),
+		},
+	}
+
+	got := request.GetRecurrent()
+	want := &easypay.Recurrent{
+		CronRule:   "0 10 1 * *",
+		DateRun:    "2030-01-01T10:00:00",
+		DateExpire: "2031-01-01T10:00:00",
+		Properties: easypay.RecurrentProperties{
+			FailedCount: 2,
+			FailedRule:  "0 12 * * *",
+			Amount:      currency.New(9900, currency.UAH),
+			UrlNotify:   "https://example.com/webhook",
+		},
+	}
+	if *got != *want {
+		t.Errorf("GetRecurrent() = %+v, want %+v", got, want)
+	}
+
+	// the recurrent amount and notify URL replace the ones of the payment
+	request.Recurrent.Amount = currency.New(5000, currency.UAH)
+	request.Recurrent.NotifyURL = utils.Ref("https://example.com/recurrent")
+
+	got = request.GetRecurrent()
+	if got.Properties.Amount.MinorUnits() != 5000 || got.Properties.UrlNotify != "https://example.com/recurrent" {
+		t.Errorf("GetRecurrent() properties = %+v, want the recurrent amount and notify URL", got.Properties)
+	}
+
+	if (&Request{}).GetRecurrent() != nil {
+		t.Error("GetRecurrent() of a request without a recurrent payment is not nil")
+	}
+}
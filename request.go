@@ -25,6 +25,9 @@
 package go_easypay
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/stremovskyy/go-easypay/currency"
 	"github.com/stremovskyy/go-easypay/easypay"
 )
@@ -34,6 +37,8 @@ type Request struct {
 	PersonalData  *PersonalData
 	PaymentData   *PaymentData
 	PaymentMethod *PaymentMethod
+	Recurrent     *Recurrent
+	Splits        []*Split
 }
 
 func (r *Request) GetRedirects() (string, string) {
@@ -60,6 +65,14 @@ func (r *Request) GetCardToken() *string {
 	return r.PaymentMethod.Card.Token
 }
 
+func (r *Request) GetCardPan() *string {
+	if r.PaymentMethod == nil || r.PaymentMethod.Card == nil {
+		return nil
+	}
+
+	return r.PaymentMethod.Card.Pan
+}
+
 func (r *Request) GetPaymentID() *string {
 	if r.PaymentData == nil {
 		return nil
@@ -93,13 +106,16 @@ func (r *Request) SetWebhookURL(webhookURL *string) {
 	r.PaymentData.WebhookURL = webhookURL
 }
 
-func (r *Request) GetAmount() float64 {
+func (r *Request) GetAmount() currency.Money {
 	if r.PaymentData == nil {
-		return 0
+		return currency.Money{}
 	}
 
-	return r.PaymentData.Amount
+	if r.PaymentData.Amount.Currency() == "" {
+		return r.PaymentData.Amount.WithCurrency(r.PaymentData.Currency)
+	}
 
+	return r.PaymentData.Amount
 }
 
 func (r *Request) GetDescription() string {
@@ -115,12 +131,42 @@ func (r *Request) GetCurrency() currency.Code {
 		return ""
 	}
 
+	if r.PaymentData.Currency == "" {
+		return r.PaymentData.Amount.Currency()
+	}
+
 	return r.PaymentData.Currency
+}
+
+// validateCurrency checks that the payment currency is known, matches the amount and is supported by the merchant
+func (r *Request) validateCurrency() error {
+	if r.PaymentData == nil {
+		return nil
+	}
+
+	code := r.GetCurrency()
+	if code == "" {
+		return nil
+	}
+
+	amountCode := r.PaymentData.Amount.Currency()
+	if r.PaymentData.Currency != "" && amountCode != "" && amountCode != r.PaymentData.Currency {
+		return fmt.Errorf("%w: amount is in %s, payment is in %s", ErrCurrencyMismatch, amountCode, r.PaymentData.Currency)
+	}
+
+	if !code.IsValid() {
+		return fmt.Errorf("%w: unknown currency %q", ErrUnsupportedCurrency, code)
+	}
 
+	if r.Merchant != nil && !r.Merchant.SupportsCurrency(code) {
+		return fmt.Errorf("%w: merchant does not accept %s", ErrUnsupportedCurrency, code)
+	}
+
+	return nil
 }
 
 func (r *Request) IsMobile() bool {
-	if r.PaymentData == nil {
+	if r.PaymentData == nil || r.PaymentMethod == nil {
 		return false
 	}
 
@@ -156,6 +202,10 @@ func (r *Request) GetCardTokenID() string {
 }
 
 func (r *Request) GetBankingDetails() *easypay.BankingDetails {
+	if r.Merchant == nil {
+		return nil
+	}
+
 	bd := &easypay.BankingDetails{
 		Payee: &easypay.Payee{
 			ID:   r.Merchant.PayeeID,
@@ -181,6 +231,39 @@ func (r *Request) GetBankingDetails() *easypay.BankingDetails {
 	return bd
 }
 
+func (r *Request) GetPayeeID() string {
+	if r.PersonalData == nil || r.PersonalData.TaxID == nil {
+		return ""
+	}
+
+	return *r.PersonalData.TaxID
+}
+
+func (r *Request) GetPayeeName() string {
+	if r.PersonalData == nil {
+		return ""
+	}
+
+	var parts []string
+	if r.PersonalData.FirstName != nil && *r.PersonalData.FirstName != "" {
+		parts = append(parts, *r.PersonalData.FirstName)
+	}
+	if r.PersonalData.LastName != nil && *r.PersonalData.LastName != "" {
+		parts = append(parts, *r.PersonalData.LastName)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// GetRefundID returns the caller's ID of the refund, empty when it is not set
+func (r *Request) GetRefundID() string {
+	if r.PaymentData == nil || r.PaymentData.RefundID == nil {
+		return ""
+	}
+
+	return *r.PaymentData.RefundID
+}
+
 func (r *Request) GetTransactionID() *int64 {
 	if r.PaymentData == nil {
 		return nil
@@ -205,3 +288,84 @@ func (r *Request) GetGoogleMerchantID() *string {
 
 	return r.Merchant.GoogleMerchantID
 }
+
+func (r *Request) GetRecurrent() *easypay.Recurrent {
+	if r.Recurrent == nil {
+		return nil
+	}
+
+	amount := r.Recurrent.Amount
+	if amount.IsZero() {
+		amount = r.GetAmount()
+	}
+
+	notifyURL := r.Recurrent.NotifyURL
+	if notifyURL == nil {
+		notifyURL = r.GetWebhookURL()
+	}
+
+	recurrent := &easypay.Recurrent{
+		CronRule:   r.Recurrent.CronRule.String(),
+		DateRun:    r.Recurrent.dateRun().Format(easypay.RecurrentDateLayout),
+		DateExpire: r.Recurrent.DateExpire.Format(easypay.RecurrentDateLayout),
+		Properties: easypay.RecurrentProperties{
+			FailedCount: r.Recurrent.FailedCount,
+			FailedRule:  r.Recurrent.FailedRule.String(),
+			Amount:      amount,
+		},
+	}
+
+	if notifyURL != nil {
+		recurrent.Properties.UrlNotify = *notifyURL
+	}
+
+	return recurrent
+}
+
+func (r *Request) GetSplitting() *easypay.Splitting {
+	if len(r.Splits) == 0 {
+		return nil
+	}
+
+	splitting := &easypay.Splitting{
+		Items: make([]easypay.SplitItem, 0, len(r.Splits)),
+	}
+
+	for _, split := range r.Splits {
+		item := easypay.SplitItem{
+			ServiceKey:       split.ServiceKey,
+			OrderID:          split.OrderID,
+			BankingDetailsID: split.BankingDetailsID,
+			Unit:             split.Unit,
+			WithCommission:   split.WithCommission,
+		}
+
+		if split.Unit == easypay.SplitUnitAmount {
+			item.Amount = split.Amount
+		} else {
+			item.Percent = split.Value
+		}
+
+		if split.BankingDetailsID == "" {
+			item.BankingDetails = &easypay.BankingDetails{
+				Payee: &easypay.Payee{
+					ID:   split.PayeeID,
+					Name: split.PayeeName,
+				},
+				Narrative: &easypay.Narrative{
+					Name: split.Narrative,
+				},
+			}
+
+			if split.PayeeBankAccount != "" {
+				item.BankingDetails.Payee.Bank = &easypay.Bank{
+					Account: split.PayeeBankAccount,
+				}
+			}
+		}
+
+		splitting.Items = append(splitting.Items, item)
+	}
+
+	return splitting
+}
@@ -0,0 +1,215 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// appCache keeps a createApp session per merchant partner key and is safe for concurrent use
+type appCache struct {
+	mu       sync.RWMutex
+	apps     map[string]*easypay.App
+	inflight map[string]*appCall
+}
+
+// appCall is a createApp request shared by every caller waiting for the same partner key
+type appCall struct {
+	done chan struct{}
+	app  *easypay.App
+	err  error
+}
+
+func newAppCache() *appCache {
+	return &appCache{
+		apps:     make(map[string]*easypay.App),
+		inflight: make(map[string]*appCall),
+	}
+}
+
+// get returns the cached App for the partner key while it is still valid
+func (a *appCache) get(partnerKey string) *easypay.App {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	app, ok := a.apps[partnerKey]
+	if !ok || !app.IsValid() {
+		return nil
+	}
+
+	return app
+}
+
+// load returns the valid App for the partner key, creating it with create when missing.
+// Concurrent callers for the same partner key share a single create call.
+func (a *appCache) load(ctx context.Context, partnerKey string, create func(context.Context) (*easypay.App, error)) (*easypay.App, error) {
+	for {
+		if app := a.get(partnerKey); app != nil {
+			return app, nil
+		}
+
+		a.mu.Lock()
+		if app, ok := a.apps[partnerKey]; ok && app.IsValid() {
+			a.mu.Unlock()
+			return app, nil
+		}
+
+		call, ok := a.inflight[partnerKey]
+		if !ok {
+			call = &appCall{done: make(chan struct{})}
+			a.inflight[partnerKey] = call
+			a.mu.Unlock()
+
+			call.app, call.err = create(ctx)
+
+			a.mu.Lock()
+			if call.err == nil {
+				a.apps[partnerKey] = call.app
+			}
+			delete(a.inflight, partnerKey)
+			a.mu.Unlock()
+			close(call.done)
+
+			return call.app, call.err
+		}
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+
+		// the leading caller was cancelled, so its failure says nothing about this caller
+		if call.err != nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			continue
+		}
+
+		return call.app, call.err
+	}
+}
+
+// invalidate drops the App for the partner key unless it was already replaced by a newer one
+func (a *appCache) invalidate(partnerKey string, app *easypay.App) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if current, ok := a.apps[partnerKey]; ok && current == app {
+		delete(a.apps, partnerKey)
+	}
+}
+
+// sessionError is a failed createApp or createPage call, the request of the operation was not sent
+type sessionError struct {
+	err error
+}
+
+func (e *sessionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+// withSession runs call with the merchant's App and a fresh Page, recreating the App once when Easypay rejects it
+func (c *client) withSession(ctx context.Context, merchant *Merchant, call func(appID string, pageID *string) error) error {
+	if merchant == nil {
+		return ErrMerchantIsNil
+	}
+
+	for attempt := 0; ; attempt++ {
+		app, err := c.getApp(ctx, merchant)
+		if err != nil {
+			return &sessionError{err: fmt.Errorf("cannot create App: %w", err)}
+		}
+
+		pageID, err := c.createPageID(ctx, merchant, app.AppID())
+		if err == nil {
+			err = call(app.AppID(), pageID)
+		} else {
+			err = &sessionError{err: fmt.Errorf("cannot create Page ID: %w", err)}
+		}
+
+		if err != nil && attempt == 0 && easypay.IsAppRejected(err) {
+			c.apps.invalidate(merchant.getPartnerKey(), app)
+			continue
+		}
+
+		return err
+	}
+}
+
+// getApp returns the cached App of the merchant or creates a new one
+func (c *client) getApp(ctx context.Context, merchant *Merchant) (*easypay.App, error) {
+	return c.apps.load(
+		ctx, merchant.getPartnerKey(), func(ctx context.Context) (*easypay.App, error) {
+			return c.createApp(ctx, merchant)
+		},
+	)
+}
+
+func (c *client) createApp(ctx context.Context, merchant *Merchant) (*easypay.App, error) {
+	createAppRequest := easypay.NewRequest(
+		consts.CreateAppPath,
+		easypay.WithOperation(OperationSession),
+		easypay.WithPartnerKeyHeader(merchant.getPartnerKey()),
+		easypay.WithRetry(),
+	)
+
+	response, err := c.easypayClient.NotRecordedApi(ctx, createAppRequest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get App response: %w", err)
+	}
+
+	if response.AppId == nil {
+		return nil, fmt.Errorf("App ID is empty in API response")
+	}
+
+	return response.App(), nil
+}
+
+func (c *client) createPageID(ctx context.Context, merchant *Merchant, appID string) (*string, error) {
+	pageRequest := easypay.NewRequest(
+		consts.CreatePagePath,
+		easypay.WithOperation(OperationSession),
+		easypay.WithPartnerKeyHeader(merchant.getPartnerKey()),
+		easypay.WithAppIDHeader(appID),
+		easypay.WithRetry(),
+	)
+
+	response, err := c.easypayClient.NotRecordedApi(ctx, pageRequest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get Page response: %w", err)
+	}
+
+	return response.PageId, nil
+}
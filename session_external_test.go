@@ -0,0 +1,114 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/consts"
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypaytest"
+)
+
+func TestClientConcurrentOperations(t *testing.T) {
+	server := easypaytest.NewServer(easypaytest.WithMerchant("partner-b", "secret-b"))
+	defer server.Close()
+
+	c := server.Client(go_easypay.WithRetryPolicy(nil))
+
+	merchants := []*go_easypay.Merchant{
+		server.Merchant(),
+		{PartnerKey: "partner-b", ServiceKey: "service-b", SecretKey: "secret-b"},
+	}
+
+	// every goroutine pays, checks and refunds its own order and holds another one
+	operations := func(merchant *go_easypay.Merchant, orderID string) error {
+		payment, err := go_easypay.NewPayment(merchant).Order(orderID).Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+		if err != nil {
+			return err
+		}
+		if _, err = c.Payment(payment); err != nil {
+			return fmt.Errorf("payment: %w", err)
+		}
+
+		status, err := go_easypay.NewStatus(merchant).Order(orderID).Build()
+		if err != nil {
+			return err
+		}
+		if _, err = c.Status(status); err != nil {
+			return fmt.Errorf("status: %w", err)
+		}
+
+		refund, err := go_easypay.NewRefund(merchant).Order(orderID).Amount(currency.New(100, currency.UAH)).Build()
+		if err != nil {
+			return err
+		}
+		if _, err = c.Refund(refund); err != nil {
+			return fmt.Errorf("refund: %w", err)
+		}
+
+		hold, err := go_easypay.NewHold(merchant).Order(orderID + "-hold").Amount(currency.New(1000, currency.UAH)).Card("token").Build()
+		if err != nil {
+			return err
+		}
+		if _, err = c.Hold(hold); err != nil {
+			return fmt.Errorf("hold: %w", err)
+		}
+
+		return nil
+	}
+
+	const goroutines = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*len(merchants))
+
+	for _, merchant := range merchants {
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(merchant *go_easypay.Merchant, orderID string) {
+				defer wg.Done()
+				if err := operations(merchant, orderID); err != nil {
+					errs <- fmt.Errorf("%s: %w", orderID, err)
+				}
+			}(merchant, fmt.Sprintf("%s-%d", merchant.PartnerKey, i))
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// one app per merchant, the cached app is shared by all goroutines
+	if calls := server.Calls(consts.CreateAppPath); calls != len(merchants) {
+		t.Errorf("createApp called %d times, want %d", calls, len(merchants))
+	}
+}
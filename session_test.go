@@ -0,0 +1,315 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+func TestAppCacheFollowerRetriesAfterCancelledLeader(t *testing.T) {
+	cache := newAppCache()
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	defer cancelLeader()
+
+	started := make(chan struct{})
+	var creates atomic.Int32
+
+	create := func(ctx context.Context) (*easypay.App, error) {
+		if creates.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		}
+
+		return easypay.NewApp(nil, nil, utils.Ref("follower-app")), nil
+	}
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.load(leaderCtx, "partner", create)
+		leaderErr <- err
+	}()
+	<-started
+
+	type result struct {
+		app *easypay.App
+		err error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		app, err := cache.load(context.Background(), "partner", create)
+		follower <- result{app, err}
+	}()
+
+	// let the follower wait on the leading call before the leader gives up
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader error = %v, want context.Canceled", err)
+	}
+
+	got := <-follower
+	if got.err != nil {
+		t.Fatalf("follower error = %v, want the App created on retry", got.err)
+	}
+	if got.app.AppID() != "follower-app" {
+		t.Errorf("follower App = %s, want follower-app", got.app.AppID())
+	}
+	if n := creates.Load(); n != 2 {
+		t.Errorf("create called %d times, want 2", n)
+	}
+	if cache.get("partner") != got.app {
+		t.Error("App created by the follower is not cached")
+	}
+}
+
+// appCreator counts the create calls of an appCache, every App is named after the partner key and the call
+type appCreator struct {
+	calls atomic.Int32
+}
+
+func (c *appCreator) create(partnerKey string) func(context.Context) (*easypay.App, error) {
+	return func(context.Context) (*easypay.App, error) {
+		return easypay.NewApp(nil, nil, utils.Ref(fmt.Sprintf("%s-app-%d", partnerKey, c.calls.Add(1)))), nil
+	}
+}
+
+func TestAppCacheIsolatesPartnerKeys(t *testing.T) {
+	cache := newAppCache()
+	creator := &appCreator{}
+
+	a, err := cache.load(context.Background(), "partner-a", creator.create("partner-a"))
+	if err != nil {
+		t.Fatalf("load error = %v", err)
+	}
+	b, err := cache.load(context.Background(), "partner-b", creator.create("partner-b"))
+	if err != nil {
+		t.Fatalf("load error = %v", err)
+	}
+
+	if a == b || a.AppID() != "partner-a-app-1" || b.AppID() != "partner-b-app-2" {
+		t.Fatalf("Apps = %s and %s, want one App per partner key", a.AppID(), b.AppID())
+	}
+
+	// a cached App is reused for its partner key only
+	if got, _ := cache.load(context.Background(), "partner-a", creator.create("partner-a")); got != a {
+		t.Errorf("second load of partner-a = %s, want the cached %s", got.AppID(), a.AppID())
+	}
+
+	cache.invalidate("partner-a", a)
+
+	if cache.get("partner-a") != nil {
+		t.Error("invalidated App of partner-a is still cached")
+	}
+	if cache.get("partner-b") != b {
+		t.Error("invalidating partner-a dropped the App of partner-b")
+	}
+
+	renewed, err := cache.load(context.Background(), "partner-a", creator.create("partner-a"))
+	if err != nil {
+		t.Fatalf("load error = %v", err)
+	}
+	if renewed.AppID() != "partner-a-app-3" {
+		t.Errorf("App after invalidate = %s, want partner-a-app-3", renewed.AppID())
+	}
+
+	// a caller holding the replaced App does not drop the renewed one
+	cache.invalidate("partner-a", a)
+	if cache.get("partner-a") != renewed {
+		t.Error("invalidating a replaced App dropped the renewed App")
+	}
+
+	if n := creator.calls.Load(); n != 3 {
+		t.Errorf("create called %d times, want 3", n)
+	}
+}
+
+func TestAppCacheSharesInflightCreate(t *testing.T) {
+	cache := newAppCache()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var creates atomic.Int32
+
+	create := func(context.Context) (*easypay.App, error) {
+		if creates.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+
+		return easypay.NewApp(nil, nil, utils.Ref("shared-app")), nil
+	}
+
+	const callers = 16
+
+	apps := make(chan *easypay.App, callers)
+	errs := make(chan error, callers)
+
+	var wg sync.WaitGroup
+	load := func() {
+		defer wg.Done()
+
+		app, err := cache.load(context.Background(), "partner", create)
+		if err != nil {
+			errs <- err
+			return
+		}
+		apps <- app
+	}
+
+	wg.Add(1)
+	go load()
+	<-started
+
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go load()
+	}
+
+	// let the other callers queue up behind the leading create
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(apps)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("load error = %v", err)
+	}
+
+	var first *easypay.App
+	for app := range apps {
+		if first == nil {
+			first = app
+		}
+		if app != first {
+			t.Errorf("load returned %p, want every caller to get the shared App %p", app, first)
+		}
+	}
+
+	if n := creates.Load(); n != 1 {
+		t.Errorf("create called %d times, want 1", n)
+	}
+}
+
+func TestAppCacheSharesLeaderFailure(t *testing.T) {
+	cache := newAppCache()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var creates atomic.Int32
+	failure := errors.New("createApp failed")
+
+	create := func(context.Context) (*easypay.App, error) {
+		if creates.Add(1) == 1 {
+			close(started)
+			<-release
+
+			return nil, failure
+		}
+
+		return easypay.NewApp(nil, nil, utils.Ref("retried-app")), nil
+	}
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.load(context.Background(), "partner", create)
+		leaderErr <- err
+	}()
+	<-started
+
+	followerErr := make(chan error, 1)
+	go func() {
+		_, err := cache.load(context.Background(), "partner", create)
+		followerErr <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	// a failure of Easypay is shared, the follower does not send another createApp
+	if err := <-leaderErr; !errors.Is(err, failure) {
+		t.Errorf("leader error = %v, want %v", err, failure)
+	}
+	if err := <-followerErr; !errors.Is(err, failure) {
+		t.Errorf("follower error = %v, want %v", err, failure)
+	}
+	if n := creates.Load(); n != 1 {
+		t.Errorf("create called %d times, want 1", n)
+	}
+
+	// nothing is cached, the next caller creates the App
+	app, err := cache.load(context.Background(), "partner", create)
+	if err != nil || app.AppID() != "retried-app" {
+		t.Errorf("load after the failure = %v, %v, want retried-app", app, err)
+	}
+}
+
+func TestAppCacheFollowerCancelled(t *testing.T) {
+	cache := newAppCache()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	create := func(context.Context) (*easypay.App, error) {
+		close(started)
+		<-release
+
+		return easypay.NewApp(nil, nil, utils.Ref("leader-app")), nil
+	}
+
+	leader := make(chan *easypay.App, 1)
+	go func() {
+		app, _ := cache.load(context.Background(), "partner", create)
+		leader <- app
+	}()
+	<-started
+
+	// a follower giving up does not wait for the leader, nor does it cancel the leading create
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := cache.load(ctx, "partner", create); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled follower error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+
+	if app := <-leader; app == nil || app.AppID() != "leader-app" || cache.get("partner") != app {
+		t.Errorf("leader App = %v, want leader-app cached", app)
+	}
+}
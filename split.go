@@ -0,0 +1,141 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+type Split struct {
+	// ServiceKey of the recipient merchant service
+	ServiceKey string
+	// OrderID of the split part
+	OrderID string
+	// BankingDetailsID references banking details registered in Easypay instead of PayeeID/PayeeName/PayeeBankAccount
+	BankingDetailsID string
+	PayeeID          string
+	PayeeName        string
+	PayeeBankAccount string
+	Narrative        string
+	// Unit defines whether the part is given by Amount or by Value
+	Unit easypay.SplitUnit
+	// Amount of the part for SplitUnitAmount splits
+	Amount currency.Money
+	// Value is the percent of the payment for SplitUnitPercent splits
+	Value          float64
+	WithCommission bool
+}
+
+// validateSplits checks that all splits use the same unit and add up to the payment amount or 100 percent.
+// The problems of every split are reported together, the total is checked only when all splits are valid.
+func validateSplits(splits []*Split, amount currency.Money) error {
+	var errs []error
+	var unit easypay.SplitUnit
+	var amountSum currency.Money
+	var percentSum float64
+
+	for i, split := range splits {
+		if split == nil {
+			errs = append(errs, fmt.Errorf("%w: split %d is nil", ErrInvalidSplit, i))
+			continue
+		}
+
+		if split.ServiceKey == "" {
+			errs = append(errs, fmt.Errorf("%w: split %d: service key is required", ErrInvalidSplit, i))
+		}
+
+		switch {
+		case split.Unit != easypay.SplitUnitAmount && split.Unit != easypay.SplitUnitPercent:
+			errs = append(errs, fmt.Errorf("%w: split %d: unknown unit %q", ErrInvalidSplit, i, split.Unit))
+			continue
+		case unit == "":
+			unit = split.Unit
+		case split.Unit != unit:
+			errs = append(errs, fmt.Errorf("%w: split %d: unit %q differs from %q, all splits must use the same unit", ErrInvalidSplit, i, split.Unit, unit))
+			continue
+		}
+
+		if split.Unit == easypay.SplitUnitPercent {
+			if split.Value <= 0 {
+				errs = append(errs, fmt.Errorf("%w: split %d: value must be positive", ErrInvalidSplit, i))
+			}
+
+			percentSum += split.Value
+			continue
+		}
+
+		if split.Amount.IsZero() || split.Amount.IsNegative() {
+			errs = append(errs, fmt.Errorf("%w: split %d: amount must be positive", ErrInvalidSplit, i))
+		}
+
+		// a payment without a currency is in the currency of its first split
+		code := amount.Currency()
+		if code == "" {
+			code = amountSum.Currency()
+		}
+
+		if splitCode := split.Amount.Currency(); splitCode != "" && code != "" && splitCode != code {
+			errs = append(errs, fmt.Errorf("split %d: %w: amount is in %s, payment is in %s", i, ErrCurrencyMismatch, splitCode, code))
+			continue
+		}
+
+		sum, err := amountSum.AddChecked(split.Amount)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("split %d: %w: %w", i, ErrCurrencyMismatch, err))
+			continue
+		}
+
+		amountSum = sum
+	}
+
+	if len(errs) > 0 || unit == "" {
+		return errors.Join(errs...)
+	}
+
+	if unit == easypay.SplitUnitPercent {
+		// percents are compared in hundredths, the precision Easypay accepts
+		if math.Round(percentSum*100) != 100*100 {
+			return fmt.Errorf("%w: splits add up to %v%%, expected 100%%", ErrSplitsMismatch, percentSum)
+		}
+
+		return nil
+	}
+
+	cmp, err := amountSum.Compare(amount)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCurrencyMismatch, err)
+	}
+
+	if cmp != 0 {
+		return fmt.Errorf("%w: splits add up to %s, expected %s", ErrSplitsMismatch, amountSum.Decimal(), amount.Decimal())
+	}
+
+	return nil
+}
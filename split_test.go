@@ -0,0 +1,270 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+func amountSplit(serviceKey string, minor int64) *Split {
+	return &Split{ServiceKey: serviceKey, Unit: easypay.SplitUnitAmount, Amount: currency.New(minor, currency.UAH)}
+}
+
+func percentSplit(serviceKey string, value float64) *Split {
+	return &Split{ServiceKey: serviceKey, Unit: easypay.SplitUnitPercent, Value: value}
+}
+
+func TestValidateSplits(t *testing.T) {
+	amount := currency.New(1000, currency.UAH)
+
+	tests := []struct {
+		name   string
+		splits []*Split
+		// amount of the payment, 10.00 UAH when it is zero
+		amount currency.Money
+		want   []error
+		// count of the problems reported together
+		count int
+	}{
+		{name: "no splits"},
+		{name: "amounts add up", splits: []*Split{amountSplit("a", 600), amountSplit("b", 400)}},
+		{name: "percents add up", splits: []*Split{percentSplit("a", 33.33), percentSplit("b", 66.67)}},
+		{
+			name:   "amounts in the payment currency without a code",
+			splits: []*Split{{ServiceKey: "a", Unit: easypay.SplitUnitAmount, Amount: currency.New(1000, "")}},
+		},
+		{
+			name:   "amounts do not add up",
+			splits: []*Split{amountSplit("a", 600), amountSplit("b", 300)},
+			want:   []error{ErrSplitsMismatch},
+			count:  1,
+		},
+		{
+			name:   "percents do not add up",
+			splits: []*Split{percentSplit("a", 50), percentSplit("b", 49.99)},
+			want:   []error{ErrSplitsMismatch},
+			count:  1,
+		},
+		{
+			name:   "nil first split",
+			splits: []*Split{nil, amountSplit("a", 1000)},
+			want:   []error{ErrInvalidSplit},
+			count:  1,
+		},
+		{
+			name:   "mixed units",
+			splits: []*Split{amountSplit("a", 500), percentSplit("b", 50)},
+			want:   []error{ErrInvalidSplit},
+			count:  1,
+		},
+		{
+			name: "every invalid split is reported",
+			splits: []*Split{
+				{Unit: easypay.SplitUnitAmount, Amount: currency.New(500, currency.UAH)},
+				{ServiceKey: "b", Unit: "share"},
+				amountSplit("c", 0),
+				{ServiceKey: "d", Unit: easypay.SplitUnitAmount, Amount: currency.New(500, currency.USD)},
+			},
+			want:  []error{ErrInvalidSplit, ErrCurrencyMismatch},
+			count: 4,
+		},
+		{
+			name: "splits in different currencies of a payment without a currency",
+			splits: []*Split{
+				{ServiceKey: "a", Unit: easypay.SplitUnitAmount, Amount: currency.New(500, currency.UAH)},
+				{ServiceKey: "b", Unit: easypay.SplitUnitAmount, Amount: currency.New(500, currency.USD)},
+			},
+			amount: currency.New(1000, ""),
+			want:   []error{ErrCurrencyMismatch},
+			count:  1,
+		},
+		{
+			name:   "non-positive percents",
+			splits: []*Split{percentSplit("a", 0), percentSplit("b", -10), percentSplit("c", 110)},
+			want:   []error{ErrInvalidSplit},
+			count:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		paymentAmount := tt.amount
+		if paymentAmount.IsZero() {
+			paymentAmount = amount
+		}
+
+		err := validateSplits(tt.splits, paymentAmount)
+
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: validateSplits() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: validateSplits() = %v, want %v", tt.name, err, want)
+			}
+		}
+
+		if count := countErrors(err); count != tt.count {
+			t.Errorf("%s: validateSplits() reported %d problems, want %d: %v", tt.name, count, tt.count, err)
+		}
+	}
+}
+
+// countErrors counts the errors joined by errors.Join
+func countErrors(err error) int {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return len(joined.Unwrap())
+	}
+
+	if err == nil {
+		return 0
+	}
+
+	return 1
+}
+
+func TestRequestValidateReportsSplitsWithOtherProblems(t *testing.T) {
+	request := validRequest()
+	request.PaymentMethod = nil
+	request.Splits = []*Split{amountSplit("", 1000)}
+
+	err := request.Validate(OperationPayment)
+
+	if !errors.Is(err, ErrPaymentMethodIsNil) || !errors.Is(err, ErrInvalidSplit) {
+		t.Errorf("Validate() = %v, want the missing payment method and the invalid split", err)
+	}
+}
+
+func TestRequestGetSplitting(t *testing.T) {
+	request := &Request{
+		Splits: []*Split{
+			{
+				ServiceKey:       "service-a",
+				OrderID:          "order-1-a",
+				PayeeID:          "1234567890",
+				PayeeName:        "Taras Shevchenko",
+				PayeeBankAccount: "UA213223130000026007233566001",
+				Narrative:        "rent",
+				Unit:             easypay.SplitUnitAmount,
+				Amount:           currency.New(600, currency.UAH),
+				Value:            60,
+				WithCommission:   true,
+			},
+			{
+				ServiceKey:       "service-b",
+				BankingDetailsID: "details-b",
+				PayeeName:        "ignored with banking details ID",
+				Unit:             easypay.SplitUnitAmount,
+				Amount:           currency.New(400, currency.UAH),
+			},
+		},
+	}
+
+	want := &easypay.Splitting{
+		Items: []easypay.SplitItem{
+			{
+				ServiceKey: "service-a",
+				OrderID:    "order-1-a",
+				BankingDetails: &easypay.BankingDetails{
+					Payee: &easypay.Payee{
+						ID:   "1234567890",
+						Name: "Taras Shevchenko",
+						Bank: &easypay.Bank{Account: "UA213223130000026007233566001"},
+					},
+					Narrative: &easypay.Narrative{Name: "rent"},
+				},
+				Unit:           easypay.SplitUnitAmount,
+				Amount:         currency.New(600, currency.UAH),
+				WithCommission: true,
+			},
+			{
+				ServiceKey:       "service-b",
+				BankingDetailsID: "details-b",
+				Unit:             easypay.SplitUnitAmount,
+				Amount:           currency.New(400, currency.UAH),
+			},
+		},
+	}
+
+	if got := request.GetSplitting(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSplitting() = %+v, want %+v", got, want)
+	}
+
+	if got := (&Request{}).GetSplitting(); got != nil {
+		t.Errorf("GetSplitting() without splits = %+v, want nil", got)
+	}
+}
+
+func TestSplittingJSON(t *testing.T) {
+	request := &Request{
+		Splits: []*Split{
+			{ServiceKey: "service-a", BankingDetailsID: "details-a", Unit: easypay.SplitUnitPercent, Value: 62.5, Amount: currency.New(600, currency.UAH)},
+			{ServiceKey: "service-b", PayeeName: "Lesya Ukrainka", Narrative: "fee", Unit: easypay.SplitUnitPercent, Value: 37.5, WithCommission: true},
+		},
+	}
+
+	data, err := json.Marshal(request.GetSplitting())
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	want := `{"items":[` +
+		`{"serviceKey":"service-a","bankingDetailsId":"details-a","unit":"percent","withCommission":false,"value":62.5},` +
+		`{"serviceKey":"service-b","bankingDetails":{"payee":{"name":"Lesya Ukrainka"},"narrative":{"name":"fee"}},"unit":"percent","withCommission":true,"value":37.5}` +
+		`]}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	request.Splits = []*Split{amountSplit("service-a", 1050)}
+
+	data, err = json.Marshal(request.GetSplitting())
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	want = `{"items":[{"serviceKey":"service-a","bankingDetails":{"payee":{},"narrative":{"name":""}},"unit":"amount","withCommission":false,"value":10.50}]}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded easypay.Splitting
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if len(decoded.Items) != 1 || decoded.Items[0].Amount.Cmp(currency.New(1050, currency.UAH)) != 0 || decoded.Items[0].Percent != 0 {
+		t.Errorf("json.Unmarshal() = %+v, want the amount of the split", decoded)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/consts"
+)
+
+// newStubServer passes the requests to the handlers of their paths. createApp and createPage succeed
+// unless a handler replaces them, tests that need the whole API use easypaytest.Server instead.
+func newStubServer(t *testing.T, handlers map[string]http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if handler, ok := handlers[r.URL.Path]; ok {
+					handler(w, r)
+					return
+				}
+
+				switch r.URL.Path {
+				case consts.CreateAppPath:
+					_, _ = w.Write([]byte(`{"appId": "app"}`))
+				case consts.CreatePagePath:
+					_, _ = w.Write([]byte(`{"pageId": "page"}`))
+				default:
+					http.NotFound(w, r)
+				}
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	return server
+}
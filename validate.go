@@ -0,0 +1,156 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// Operation names a client call, requests are validated for the operation they are sent with
+// and retries can be enabled or disabled per operation in the RetryPolicy
+type Operation = easypay.Operation
+
+const (
+	// OperationSession is the createApp and createPage calls made before every operation, it is not validated
+	OperationSession         Operation = "session"
+	OperationVerification    Operation = "verification"
+	OperationStatus          Operation = "status"
+	OperationPaymentURL      Operation = "payment_url"
+	OperationPayment         Operation = "payment"
+	OperationHold            Operation = "hold"
+	OperationCapture         Operation = "capture"
+	OperationRefund          Operation = "refund"
+	OperationVoid            Operation = "void"
+	OperationCredit          Operation = "credit"
+	OperationCreateRecurrent Operation = "create_recurrent"
+)
+
+// Validate checks the request before it is sent for the operation. All missing or invalid fields
+// are reported together, the result matches the sentinel errors of every problem with errors.Is.
+func (r *Request) Validate(op Operation) error {
+	if r == nil {
+		return ErrRequestIsNil
+	}
+
+	var errs []error
+
+	if r.Merchant == nil {
+		errs = append(errs, ErrMerchantIsNil)
+	} else if r.Merchant.PartnerKey == "" {
+		errs = append(errs, ErrPartnerKeyIsEmpty)
+	}
+
+	if r.PaymentData == nil {
+		errs = append(errs, ErrPaymentDataIsNil)
+
+		return errors.Join(errs...)
+	}
+
+	switch op {
+	case OperationVerification:
+		if r.GetPaymentID() == nil || *r.GetPaymentID() == "" {
+			errs = append(errs, ErrPaymentIDIsNil)
+		}
+	case OperationStatus, OperationVoid:
+		errs = append(errs, r.validateOrderReference())
+	case OperationCapture, OperationRefund:
+		errs = append(errs, r.validateOrderReference())
+
+		// a zero amount captures or refunds the whole order
+		if r.GetAmount().IsNegative() {
+			errs = append(errs, ErrAmountIsNegative)
+		}
+
+		errs = append(errs, r.validateCurrency())
+	case OperationPaymentURL, OperationPayment, OperationHold, OperationCredit, OperationCreateRecurrent:
+		errs = append(errs, r.validateNewOrder())
+		errs = append(errs, r.validateCurrency())
+	default:
+		errs = append(errs, fmt.Errorf("unknown operation %q", op))
+	}
+
+	switch op {
+	case OperationPayment, OperationHold:
+		errs = append(errs, r.validatePaymentInstrument())
+		errs = append(errs, validateSplits(r.Splits, r.GetAmount()))
+	case OperationCredit:
+		if r.PersonalData == nil {
+			errs = append(errs, ErrPersonalDataIsNil)
+		}
+
+		if r.GetCardPan() == nil && r.GetCardToken() == nil {
+			errs = append(errs, fmt.Errorf("%w: recipient card PAN or token is required", ErrPaymentInstrumentIsNil))
+		}
+	case OperationCreateRecurrent:
+		if r.Recurrent == nil {
+			errs = append(errs, ErrRecurrentIsNil)
+		} else if err := r.Recurrent.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid recurrent: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateOrderReference checks that the request addresses an existing order
+func (r *Request) validateOrderReference() error {
+	if (r.GetPaymentID() == nil || *r.GetPaymentID() == "") && r.GetTransactionID() == nil {
+		return ErrOrderReferenceIsNil
+	}
+
+	return nil
+}
+
+// validateNewOrder checks the fields every created order needs
+func (r *Request) validateNewOrder() error {
+	var errs []error
+
+	if r.GetPaymentID() == nil || *r.GetPaymentID() == "" {
+		errs = append(errs, ErrPaymentIDIsNil)
+	}
+
+	amount := r.GetAmount()
+	if amount.IsZero() || amount.IsNegative() {
+		errs = append(errs, ErrAmountIsNotPositive)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validatePaymentInstrument checks that a card token or a wallet payment is given
+func (r *Request) validatePaymentInstrument() error {
+	if r.PaymentMethod == nil {
+		return ErrPaymentMethodIsNil
+	}
+
+	if r.IsMobile() || r.GetCardToken() != nil {
+		return nil
+	}
+
+	return ErrPaymentInstrumentIsNil
+}
@@ -0,0 +1,297 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-easypay/currency"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/utils"
+)
+
+var validatedOperations = []Operation{
+	OperationVerification,
+	OperationStatus,
+	OperationPaymentURL,
+	OperationPayment,
+	OperationHold,
+	OperationCapture,
+	OperationRefund,
+	OperationVoid,
+	OperationCredit,
+	OperationCreateRecurrent,
+}
+
+// newOrderOperations create an order and need a payment ID and a positive amount
+var newOrderOperations = []Operation{
+	OperationPaymentURL,
+	OperationPayment,
+	OperationHold,
+	OperationCredit,
+	OperationCreateRecurrent,
+}
+
+// existingOrderOperations address an order by its payment ID or Easypay payment ID
+var existingOrderOperations = []Operation{
+	OperationStatus,
+	OperationCapture,
+	OperationRefund,
+	OperationVoid,
+}
+
+// validRequest passes the validation of every operation
+func validRequest() *Request {
+	return &Request{
+		Merchant: &Merchant{PartnerKey: "partner", ServiceKey: "service", SecretKey: "secret"},
+		PaymentData: &PaymentData{
+			EasypayPaymentID: utils.Ref(int64(100001)),
+			PaymentID:        utils.Ref("order-1"),
+			Amount:           currency.New(1000, currency.UAH),
+		},
+		PaymentMethod: &PaymentMethod{Card: &Card{Token: utils.Ref("token")}},
+		PersonalData:  &PersonalData{},
+		Recurrent: &Recurrent{
+			CronRule:   easypay.Daily(10, 0),
+			DateExpire: time.Now().AddDate(1, 0, 0),
+		},
+	}
+}
+
+// with returns the operations followed by the extra ones
+func with(ops []Operation, extra ...Operation) []Operation {
+	return append(append([]Operation(nil), ops...), extra...)
+}
+
+// expect maps every given operation to the wanted errors
+func expect(want map[Operation][]error, ops []Operation, errs ...error) map[Operation][]error {
+	if want == nil {
+		want = make(map[Operation][]error)
+	}
+
+	for _, op := range ops {
+		want[op] = append(want[op], errs...)
+	}
+
+	return want
+}
+
+func TestRequestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(r *Request) *Request
+		// want lists the errors of every operation, operations that are not listed pass
+		want map[Operation][]error
+	}{
+		{
+			name:   "valid",
+			mutate: func(r *Request) *Request { return r },
+		},
+		{
+			name:   "nil request",
+			mutate: func(*Request) *Request { return nil },
+			want:   expect(nil, validatedOperations, ErrRequestIsNil),
+		},
+		{
+			name: "nil merchant",
+			mutate: func(r *Request) *Request {
+				r.Merchant = nil
+				return r
+			},
+			want: expect(nil, validatedOperations, ErrMerchantIsNil),
+		},
+		{
+			name: "empty partner key",
+			mutate: func(r *Request) *Request {
+				r.Merchant.PartnerKey = ""
+				return r
+			},
+			want: expect(nil, validatedOperations, ErrPartnerKeyIsEmpty),
+		},
+		{
+			name: "nil payment data",
+			mutate: func(r *Request) *Request {
+				r.PaymentData = nil
+				return r
+			},
+			want: expect(nil, with(with(newOrderOperations, existingOrderOperations...), OperationVerification), ErrPaymentDataIsNil),
+		},
+		{
+			name: "nil payment method",
+			mutate: func(r *Request) *Request {
+				r.PaymentMethod = nil
+				return r
+			},
+			want: expect(
+				expect(nil, []Operation{OperationPayment, OperationHold}, ErrPaymentMethodIsNil),
+				[]Operation{OperationCredit}, ErrPaymentInstrumentIsNil,
+			),
+		},
+		{
+			name: "no card token",
+			mutate: func(r *Request) *Request {
+				r.PaymentMethod.Card.Token = nil
+				return r
+			},
+			want: expect(nil, []Operation{OperationPayment, OperationHold, OperationCredit}, ErrPaymentInstrumentIsNil),
+		},
+		{
+			name: "wallet payment",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.IsMobile = true
+				r.PaymentMethod = &PaymentMethod{GoogleToken: utils.Ref("google-token")}
+				return r
+			},
+			want: expect(nil, []Operation{OperationCredit}, ErrPaymentInstrumentIsNil),
+		},
+		{
+			name: "zero amount",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.Amount = currency.New(0, currency.UAH)
+				return r
+			},
+			want: expect(nil, newOrderOperations, ErrAmountIsNotPositive),
+		},
+		{
+			name: "negative amount",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.Amount = currency.New(-100, currency.UAH)
+				return r
+			},
+			want: expect(
+				expect(nil, newOrderOperations, ErrAmountIsNotPositive),
+				[]Operation{OperationCapture, OperationRefund}, ErrAmountIsNegative,
+			),
+		},
+		{
+			name: "merchant without currencies",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.Amount = currency.New(1000, currency.USD)
+				return r
+			},
+		},
+		{
+			name: "unsupported currency",
+			mutate: func(r *Request) *Request {
+				r.Merchant.Currencies = []currency.Code{currency.UAH}
+				r.PaymentData.Amount = currency.New(1000, currency.USD)
+				return r
+			},
+			want: expect(
+				nil, with(newOrderOperations, OperationCapture, OperationRefund),
+				ErrUnsupportedCurrency,
+			),
+		},
+		{
+			name: "no payment ID",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.PaymentID = nil
+				return r
+			},
+			want: expect(nil, with(newOrderOperations, OperationVerification), ErrPaymentIDIsNil),
+		},
+		{
+			name: "empty payment ID",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.PaymentID = utils.Ref("")
+				return r
+			},
+			want: expect(nil, with(newOrderOperations, OperationVerification), ErrPaymentIDIsNil),
+		},
+		{
+			name: "no order reference",
+			mutate: func(r *Request) *Request {
+				r.PaymentData.PaymentID = nil
+				r.PaymentData.EasypayPaymentID = nil
+				return r
+			},
+			want: expect(
+				expect(nil, existingOrderOperations, ErrOrderReferenceIsNil),
+				with(newOrderOperations, OperationVerification), ErrPaymentIDIsNil,
+			),
+		},
+		{
+			name: "nil recurrent",
+			mutate: func(r *Request) *Request {
+				r.Recurrent = nil
+				return r
+			},
+			want: expect(nil, []Operation{OperationCreateRecurrent}, ErrRecurrentIsNil),
+		},
+		{
+			name: "nil personal data",
+			mutate: func(r *Request) *Request {
+				r.PersonalData = nil
+				return r
+			},
+			want: expect(nil, []Operation{OperationCredit}, ErrPersonalDataIsNil),
+		},
+		{
+			name: "several problems",
+			mutate: func(r *Request) *Request {
+				r.Merchant = nil
+				r.PaymentData.PaymentID = nil
+				r.PaymentData.Amount = currency.New(0, currency.UAH)
+				r.PaymentMethod = nil
+				return r
+			},
+			want: expect(
+				expect(
+					expect(nil, validatedOperations, ErrMerchantIsNil),
+					with(newOrderOperations, OperationVerification), ErrPaymentIDIsNil,
+				),
+				[]Operation{OperationPayment, OperationHold}, ErrPaymentMethodIsNil, ErrAmountIsNotPositive,
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		for _, op := range validatedOperations {
+			err := tt.mutate(validRequest()).Validate(op)
+			want := tt.want[op]
+
+			if len(want) == 0 {
+				if err != nil {
+					t.Errorf("%s: Validate(%s) = %v, want nil", tt.name, op, err)
+				}
+				continue
+			}
+
+			for _, wantErr := range want {
+				if !errors.Is(err, wantErr) {
+					t.Errorf("%s: Validate(%s) = %v, want it to match %q", tt.name, op, err, wantErr)
+				}
+			}
+		}
+	}
+}
+
+func TestRequestValidateUnknownOperation(t *testing.T) {
+	if err := validRequest().Validate("transfer"); err == nil {
+		t.Error("Validate(transfer) = nil, want an error")
+	}
+}
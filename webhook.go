@@ -0,0 +1,78 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/internal/http"
+)
+
+// WebhookSignatureHeader is the header carrying the signature of Easypay notifications
+const WebhookSignatureHeader = "Sign"
+
+// VerifyWebhook checks the notification signature against the merchant secret key and its previous keys
+func VerifyWebhook(body []byte, signature string, merchant *Merchant) error {
+	if merchant == nil {
+		return ErrMerchantIsNil
+	}
+
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return ErrWebhookSignatureMissing
+	}
+
+	secrets := append([]string{merchant.GetSecretKey()}, merchant.PreviousSecretKeys...)
+
+	valid := false
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		expected := http.ComputeSignature(secret, string(body))
+		// every secret is checked to keep the timing independent of which one matched
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1 {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return ErrWebhookSignatureInvalid
+	}
+
+	return nil
+}
+
+// ParseVerifiedWebhook verifies the notification signature and parses its body
+func ParseVerifiedWebhook(body []byte, signature string, merchant *Merchant) (*easypay.Webhook, error) {
+	if err := VerifyWebhook(body, signature, merchant); err != nil {
+		return nil, err
+	}
+
+	return easypay.ParseWebhook(body)
+}
@@ -0,0 +1,262 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/stremovskyy/go-easypay/easypay"
+)
+
+// defaultDedupTTL covers the whole redelivery window of Easypay notifications
+const defaultDedupTTL = 72 * time.Hour
+
+// dedupSweepInterval is how often the memory store drops expired keys
+const dedupSweepInterval = time.Minute
+
+// dedupReserveAttempts bounds the retries of a Redis reservation whose key expires while it is read
+const dedupReserveAttempts = 3
+
+// DedupStatus is the state of a notification key in a DedupStore
+type DedupStatus int
+
+const (
+	// DedupReserved means the key was free and is now reserved for the delivery
+	DedupReserved DedupStatus = iota
+	// DedupInProgress means another delivery of the notification is being dispatched
+	DedupInProgress
+	// DedupDone means the notification was already processed
+	DedupDone
+)
+
+// DedupStore remembers notifications that were processed, so redeliveries are acknowledged without being dispatched again.
+// A key is reserved for a short lease while its notification is dispatched and is kept for the store TTL once it is done,
+// so a crash during dispatch only delays the redelivery until the lease expires.
+type DedupStore interface {
+	// Reserve marks the key as in progress for lease, unless it is already in progress or done.
+	// The returned token identifies the reservation and is only set when the key was reserved.
+	Reserve(ctx context.Context, key string, lease time.Duration) (DedupStatus, string, error)
+	// Complete marks the key as done after its notification was dispatched
+	Complete(ctx context.Context, key string) error
+	// Release forgets the key while it is still held by the reservation with token, so the next delivery
+	// of the notification is dispatched again. A reservation taken over after its lease expired is kept.
+	Release(ctx context.Context, key string, token string) error
+}
+
+// DedupKey identifies a notification by its order, payment and event type
+func DedupKey(event easypay.Event) string {
+	w := event.Webhook()
+
+	if tokenized, ok := event.(*easypay.CardTokenizedEvent); ok {
+		return strings.Join([]string{string(event.Type()), tokenized.Phone, tokenized.CardGuid}, ":")
+	}
+
+	paymentID := ""
+	if w.Details != nil {
+		paymentID = fmt.Sprintf("%d", w.Details.PaymentId)
+	}
+
+	return strings.Join([]string{string(event.Type()), w.OrderId, paymentID, w.Action}, ":")
+}
+
+type memoryDedupStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	keys      map[string]dedupEntry
+	nextSweep time.Time
+}
+
+type dedupEntry struct {
+	token     string
+	done      bool
+	expiresAt time.Time
+}
+
+// NewMemoryDedupStore keeps processed keys in memory for ttl, suitable for a single instance
+func NewMemoryDedupStore(ttl time.Duration) DedupStore {
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+
+	return &memoryDedupStore{
+		ttl:  ttl,
+		keys: make(map[string]dedupEntry),
+	}
+}
+
+func (s *memoryDedupStore) Reserve(_ context.Context, key string, lease time.Duration) (DedupStatus, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if entry, ok := s.keys[key]; ok && now.Before(entry.expiresAt) {
+		if entry.done {
+			return DedupDone, "", nil
+		}
+
+		return DedupInProgress, "", nil
+	}
+
+	token := uuid.NewString()
+	s.keys[key] = dedupEntry{token: token, expiresAt: now.Add(lease)}
+
+	return DedupReserved, token, nil
+}
+
+func (s *memoryDedupStore) Complete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = dedupEntry{done: true, expiresAt: time.Now().Add(s.ttl)}
+
+	return nil
+}
+
+func (s *memoryDedupStore) Release(_ context.Context, key string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.keys[key]; ok && !entry.done && entry.token == token {
+		delete(s.keys, key)
+	}
+
+	return nil
+}
+
+// sweep drops expired keys at most once per dedupSweepInterval, so a Reserve does not scan every key
+func (s *memoryDedupStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+
+	for key, entry := range s.keys {
+		if !now.Before(entry.expiresAt) {
+			delete(s.keys, key)
+		}
+	}
+
+	s.nextSweep = now.Add(dedupSweepInterval)
+}
+
+type redisDedupStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// Values of the Redis keys, a reserved key holds the in progress prefix followed by the reservation token
+const (
+	dedupInProgressValue = "in_progress:"
+	dedupDoneValue       = "done"
+)
+
+// releaseScript deletes the key only while it still holds the reservation of the caller
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// NewRedisDedupStore keeps processed keys in Redis for ttl, so every instance behind a load balancer shares them
+func NewRedisDedupStore(client redis.UniversalClient, prefix string, ttl time.Duration) DedupStore {
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+
+	if prefix == "" {
+		prefix = "easypay:webhook:dedup"
+	}
+
+	return &redisDedupStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (s *redisDedupStore) Reserve(ctx context.Context, key string, lease time.Duration) (DedupStatus, string, error) {
+	for attempt := 0; attempt < dedupReserveAttempts; attempt++ {
+		token := uuid.NewString()
+
+		reserved, err := s.client.SetNX(ctx, s.key(key), dedupInProgressValue+token, lease).Result()
+		if err != nil {
+			return 0, "", fmt.Errorf("cannot reserve webhook key: %w", err)
+		}
+
+		if reserved {
+			return DedupReserved, token, nil
+		}
+
+		value, err := s.client.Get(ctx, s.key(key)).Result()
+		if errors.Is(err, redis.Nil) {
+			// the lease expired in between, the key is free to be reserved again
+			continue
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("cannot get webhook key: %w", err)
+		}
+
+		if value == dedupDoneValue {
+			return DedupDone, "", nil
+		}
+
+		return DedupInProgress, "", nil
+	}
+
+	return 0, "", fmt.Errorf("cannot reserve webhook key: it expired %d times while being reserved", dedupReserveAttempts)
+}
+
+func (s *redisDedupStore) Complete(ctx context.Context, key string) error {
+	err := s.client.Set(ctx, s.key(key), dedupDoneValue, s.ttl).Err()
+	if err != nil {
+		return fmt.Errorf("cannot complete webhook key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisDedupStore) Release(ctx context.Context, key string, token string) error {
+	err := releaseScript.Run(ctx, s.client, []string{s.key(key)}, dedupInProgressValue+token).Err()
+	if err != nil {
+		return fmt.Errorf("cannot release webhook key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *redisDedupStore) key(key string) string {
+	return s.prefix + ":" + key
+}
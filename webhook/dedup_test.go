@@ -0,0 +1,154 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	testDedupStore(
+		t, NewMemoryDedupStore(time.Hour), func(d time.Duration) {
+			time.Sleep(d)
+		},
+	)
+}
+
+func TestRedisDedupStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	testDedupStore(t, NewRedisDedupStore(client, "", time.Hour), server.FastForward)
+
+	if !server.Exists("easypay:webhook:dedup:a") {
+		t.Fatal("done key is not stored under the default prefix")
+	}
+	if ttl := server.TTL("easypay:webhook:dedup:a"); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("done key TTL = %v, want the store TTL", ttl)
+	}
+}
+
+// expiringGetClient deletes the key right before the first Get, as if its lease expired between SetNX and Get
+type expiringGetClient struct {
+	redis.UniversalClient
+	server  *miniredis.Miniredis
+	expired bool
+}
+
+func (c *expiringGetClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	if !c.expired {
+		c.expired = true
+		c.server.Del(key)
+	}
+
+	return c.UniversalClient.Get(ctx, key)
+}
+
+func TestRedisDedupStoreRetriesExpiredReservation(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	if err := server.Set("test:a", dedupInProgressValue+"other"); err != nil {
+		t.Fatalf("Set error = %v", err)
+	}
+
+	store := NewRedisDedupStore(&expiringGetClient{UniversalClient: client, server: server}, "test", time.Hour)
+
+	status, token, err := store.Reserve(ctx, "a", time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve error = %v", err)
+	}
+	if status != DedupReserved || token == "" {
+		t.Fatalf("Reserve = %d, %q, want a reservation", status, token)
+	}
+
+	if value, _ := server.Get("test:a"); value != dedupInProgressValue+token {
+		t.Fatalf("reserved value = %q, want the reservation token", value)
+	}
+}
+
+// testDedupStore runs the behaviour shared by every store, expire lets the leases of the store run out
+func testDedupStore(t *testing.T, store DedupStore, expire func(time.Duration)) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	reserve := func(key string, lease time.Duration, want DedupStatus) string {
+		t.Helper()
+
+		status, token, err := store.Reserve(ctx, key, lease)
+		if err != nil {
+			t.Fatalf("Reserve(%s) error = %v", key, err)
+		}
+		if status != want {
+			t.Fatalf("Reserve(%s) = %d, want %d", key, status, want)
+		}
+		if (status == DedupReserved) != (token != "") {
+			t.Fatalf("Reserve(%s) = %d with token %q", key, status, token)
+		}
+
+		return token
+	}
+
+	release := func(key, token string) {
+		t.Helper()
+
+		if err := store.Release(ctx, key, token); err != nil {
+			t.Fatalf("Release(%s) error = %v", key, err)
+		}
+	}
+
+	token := reserve("a", time.Minute, DedupReserved)
+	reserve("a", time.Minute, DedupInProgress)
+
+	if err := store.Complete(ctx, "a"); err != nil {
+		t.Fatalf("Complete error = %v", err)
+	}
+	reserve("a", time.Minute, DedupDone)
+
+	// a done key is not forgotten by a late release
+	release("a", token)
+	reserve("a", time.Minute, DedupDone)
+
+	// a delivery that crashed during dispatch is reserved again once its lease expires
+	stale := reserve("b", 10*time.Millisecond, DedupReserved)
+	expire(20 * time.Millisecond)
+	current := reserve("b", time.Minute, DedupReserved)
+
+	// the delivery whose lease expired does not release the reservation that took over
+	release("b", stale)
+	reserve("b", time.Minute, DedupInProgress)
+
+	release("b", current)
+	reserve("b", time.Minute, DedupReserved)
+}
@@ -0,0 +1,274 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/easypay"
+	"github.com/stremovskyy/go-easypay/log"
+)
+
+// defaultMaxBodySize limits the notification body read by the handler
+const defaultMaxBodySize = 1 << 20
+
+// defaultDedupLease is how long a notification stays reserved while it is dispatched
+const defaultDedupLease = 5 * time.Minute
+
+// ErrInProgress is returned while another delivery of the same notification is being dispatched
+var ErrInProgress = errors.New("webhook is being processed")
+
+// ErrUnexpectedEvent is returned when a typed callback receives an event of another type
+var ErrUnexpectedEvent = errors.New("unexpected webhook event")
+
+// EventKind is the kind of notification a callback is registered for
+type EventKind = easypay.EventType
+
+const (
+	EventCardTokenized = easypay.EventTypeCardTokenized
+	EventPayment       = easypay.EventTypePayment
+	EventHold          = easypay.EventTypeHold
+	EventRefund        = easypay.EventTypeRefund
+	EventRecurrent     = easypay.EventTypeRecurrent
+	EventUnknown       = easypay.EventTypeUnknown
+)
+
+// HandlerFunc processes a verified notification, a returned error makes Easypay redeliver it
+type HandlerFunc func(ctx context.Context, event easypay.Event) error
+
+// Handler is an http.Handler that verifies, parses and dispatches Easypay notifications
+type Handler struct {
+	merchant    *go_easypay.Merchant
+	maxBodySize int64
+	dedup       DedupStore
+	dedupLease  time.Duration
+	logger      *log.Logger
+
+	mu       sync.RWMutex
+	handlers map[EventKind]HandlerFunc
+}
+
+type Option func(*Handler)
+
+// WithMaxBodySize overrides the maximum accepted notification size in bytes
+func WithMaxBodySize(size int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = size
+	}
+}
+
+// WithDedupStore acknowledges redelivered notifications without dispatching them again
+func WithDedupStore(store DedupStore) Option {
+	return func(h *Handler) {
+		h.dedup = store
+	}
+}
+
+// WithDedupLease sets how long a notification stays reserved while it is dispatched, a redelivery after a crash
+// is dispatched once the lease expires
+func WithDedupLease(lease time.Duration) Option {
+	return func(h *Handler) {
+		h.dedupLease = lease
+	}
+}
+
+func NewHandler(merchant *go_easypay.Merchant, options ...Option) *Handler {
+	h := &Handler{
+		merchant:    merchant,
+		maxBodySize: defaultMaxBodySize,
+		dedupLease:  defaultDedupLease,
+		logger:      log.NewLogger("easypay webhook:"),
+		handlers:    make(map[EventKind]HandlerFunc),
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+// On registers the callback for the event kind, replacing the previous one
+func (h *Handler) On(kind EventKind, fn HandlerFunc) *Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers[kind] = fn
+
+	return h
+}
+
+func (h *Handler) OnCardTokenized(fn func(ctx context.Context, event *easypay.CardTokenizedEvent) error) *Handler {
+	return h.On(EventCardTokenized, typed(fn))
+}
+
+func (h *Handler) OnPayment(fn func(ctx context.Context, event *easypay.PaymentEvent) error) *Handler {
+	return h.On(EventPayment, typed(fn))
+}
+
+func (h *Handler) OnHold(fn func(ctx context.Context, event *easypay.HoldEvent) error) *Handler {
+	return h.On(EventHold, typed(fn))
+}
+
+func (h *Handler) OnRefund(fn func(ctx context.Context, event *easypay.RefundEvent) error) *Handler {
+	return h.On(EventRefund, typed(fn))
+}
+
+func (h *Handler) OnRecurrent(fn func(ctx context.Context, event *easypay.RecurrentEvent) error) *Handler {
+	return h.On(EventRecurrent, typed(fn))
+}
+
+// typed adapts a callback of a concrete event type, an event of another type is returned as an error
+func typed[E easypay.Event](fn func(ctx context.Context, event E) error) HandlerFunc {
+	return func(ctx context.Context, event easypay.Event) error {
+		typedEvent, ok := event.(E)
+		if !ok {
+			return fmt.Errorf("%w: got %T for %s", ErrUnexpectedEvent, event, event.Type())
+		}
+
+		return fn(ctx, typedEvent)
+	}
+}
+
+// OnUnknown registers the callback for notifications of an unrecognised kind
+func (h *Handler) OnUnknown(fn HandlerFunc) *Handler {
+	return h.On(EventUnknown, fn)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, h.maxBodySize+1))
+	if err != nil {
+		h.logger.Error("cannot read webhook body: %v", err)
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+
+	if int64(len(body)) > h.maxBodySize {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = go_easypay.VerifyWebhook(body, r.Header.Get(go_easypay.WebhookSignatureHeader), h.merchant)
+	if err != nil {
+		h.logger.Warning("webhook rejected: %v", err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, go_easypay.ErrMerchantIsNil) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, "invalid signature", status)
+		return
+	}
+
+	event, err := easypay.ParseEvent(body)
+	if err != nil {
+		h.logger.Error("cannot parse webhook: %v", err)
+		http.Error(w, "cannot parse body", http.StatusBadRequest)
+		return
+	}
+
+	err = h.process(r.Context(), event)
+	if errors.Is(err, ErrInProgress) {
+		h.logger.Warning("webhook %s: %v", DedupKey(event), err)
+		http.Error(w, "webhook is being processed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.logger.Error("webhook handler failed: %v", err)
+		http.Error(w, "handler failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// process dispatches the event once. The key is reserved for the dedup lease while the event is dispatched
+// and marked done afterwards, a failed dispatch releases it for the redelivery.
+func (h *Handler) process(ctx context.Context, event easypay.Event) error {
+	if h.dedup == nil {
+		return h.dispatch(ctx, event)
+	}
+
+	key := DedupKey(event)
+
+	status, token, err := h.dedup.Reserve(ctx, key, h.dedupLease)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case DedupDone:
+		h.logger.Debug("duplicate webhook %s skipped", key)
+		return nil
+	case DedupInProgress:
+		return ErrInProgress
+	}
+
+	if err = h.dispatch(ctx, event); err != nil {
+		// let the redelivery of a failed notification be dispatched again
+		if releaseErr := h.dedup.Release(context.WithoutCancel(ctx), key, token); releaseErr != nil {
+			h.logger.Error("cannot release webhook %s: %v", key, releaseErr)
+		}
+
+		return err
+	}
+
+	// the event was dispatched, a failure only lets a redelivery after the lease be dispatched again
+	if err = h.dedup.Complete(context.WithoutCancel(ctx), key); err != nil {
+		h.logger.Error("cannot complete webhook %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// dispatch runs the callback registered for the event type, events without a callback are acknowledged
+func (h *Handler) dispatch(ctx context.Context, event easypay.Event) error {
+	h.mu.RLock()
+	fn, ok := h.handlers[event.Type()]
+	h.mu.RUnlock()
+
+	if !ok {
+		h.logger.Debug("no handler for %s webhook of order %s", event.Type(), event.Webhook().OrderId)
+		return nil
+	}
+
+	if err := fn(ctx, event); err != nil {
+		return fmt.Errorf("%s handler: %w", event.Type(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	go_easypay "github.com/stremovskyy/go-easypay"
+	"github.com/stremovskyy/go-easypay/easypay"
+	internalhttp "github.com/stremovskyy/go-easypay/internal/http"
+)
+
+const paymentBody = `{"action":"payment","order_id":"order-1","details":{"amount":10.50,"payment_id":42}}`
+
+var testMerchant = &go_easypay.Merchant{SecretKey: "secret"}
+
+func newWebhookRequest(method, body, signature string) *http.Request {
+	r := httptest.NewRequest(method, "/webhook", strings.NewReader(body))
+	if signature != "" {
+		r.Header.Set(go_easypay.WebhookSignatureHeader, signature)
+	}
+
+	return r
+}
+
+func sign(body string) string {
+	return internalhttp.ComputeSignature(testMerchant.GetSecretKey(), body)
+}
+
+func TestHandlerServeHTTP(t *testing.T) {
+	failing := func(ctx context.Context, event *easypay.PaymentEvent) error {
+		return errors.New("storage is down")
+	}
+	succeeding := func(ctx context.Context, event *easypay.PaymentEvent) error {
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		handler *Handler
+		request *http.Request
+		want    int
+	}{
+		{
+			name:    "method not allowed",
+			handler: NewHandler(testMerchant).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodGet, "", ""),
+			want:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:    "body too large",
+			handler: NewHandler(testMerchant, WithMaxBodySize(16)).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)),
+			want:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "missing signature",
+			handler: NewHandler(testMerchant).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, paymentBody, ""),
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "invalid signature",
+			handler: NewHandler(testMerchant).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(`{"action":"payment"}`)),
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "merchant not configured",
+			handler: NewHandler(nil).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)),
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name:    "body is not json",
+			handler: NewHandler(testMerchant).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, `{"action":`, sign(`{"action":`)),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "handler failed",
+			handler: NewHandler(testMerchant).OnPayment(failing),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)),
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name:    "dispatched",
+			handler: NewHandler(testMerchant).OnPayment(succeeding),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)),
+			want:    http.StatusOK,
+		},
+		{
+			name:    "no callback registered",
+			handler: NewHandler(testMerchant),
+			request: newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)),
+			want:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		tt.handler.ServeHTTP(recorder, tt.request)
+
+		if recorder.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, recorder.Code, tt.want)
+		}
+	}
+}
+
+func TestHandlerDispatchesTypedEvent(t *testing.T) {
+	var got *easypay.PaymentEvent
+	handler := NewHandler(testMerchant).OnPayment(
+		func(ctx context.Context, event *easypay.PaymentEvent) error {
+			got = event
+			return nil
+		},
+	)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if got == nil || got.OrderID != "order-1" || got.PaymentID != 42 {
+		t.Fatalf("dispatched event = %+v, want order-1 payment 42", got)
+	}
+}
+
+func TestHandlerDedup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDedupStore(time.Hour)
+
+	calls := 0
+	handler := NewHandler(testMerchant, WithDedupStore(store)).OnPayment(
+		func(ctx context.Context, event *easypay.PaymentEvent) error {
+			calls++
+			return nil
+		},
+	)
+
+	event, err := easypay.ParseEvent([]byte(paymentBody))
+	if err != nil {
+		t.Fatalf("ParseEvent error = %v", err)
+	}
+
+	// another delivery of the same notification is being dispatched
+	_, token, err := store.Reserve(ctx, DedupKey(event), time.Minute)
+	if err != nil {
+		t.Fatalf("Reserve error = %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)))
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("in progress status = %d, want %d", recorder.Code, http.StatusConflict)
+	}
+	if calls != 0 {
+		t.Fatalf("in progress webhook dispatched %d times", calls)
+	}
+
+	if err = store.Release(ctx, DedupKey(event), token); err != nil {
+		t.Fatalf("Release error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, newWebhookRequest(http.MethodPost, paymentBody, sign(paymentBody)))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("delivery %d status = %d, want %d", i+1, recorder.Code, http.StatusOK)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("webhook dispatched %d times, want 1", calls)
+	}
+}
+
+func TestTypedRejectsOtherEvents(t *testing.T) {
+	fn := typed(
+		func(ctx context.Context, event *easypay.PaymentEvent) error {
+			t.Fatal("callback called with another event type")
+			return nil
+		},
+	)
+
+	if err := fn(context.Background(), &easypay.HoldEvent{}); !errors.Is(err, ErrUnexpectedEvent) {
+		t.Fatalf("typed() error = %v, want %v", err, ErrUnexpectedEvent)
+	}
+}
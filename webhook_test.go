@@ -0,0 +1,107 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_easypay
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stremovskyy/go-easypay/internal/http"
+)
+
+const webhookBody = `{"action":"payment","order_id":"order-1","date":"2024-05-01T10:00:00","details":{"amount":10.50,"payment_id":42}}`
+
+func TestVerifyWebhook(t *testing.T) {
+	merchant := &Merchant{SecretKey: "current", PreviousSecretKeys: []string{"", "previous"}}
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		merchant  *Merchant
+		wantErr   error
+	}{
+		{name: "valid signature", body: webhookBody, signature: http.ComputeSignature("current", webhookBody), merchant: merchant},
+		{name: "signature padded with spaces", body: webhookBody, signature: " " + http.ComputeSignature("current", webhookBody) + "\n", merchant: merchant},
+		{name: "previous secret key", body: webhookBody, signature: http.ComputeSignature("previous", webhookBody), merchant: merchant},
+		{
+			name:      "previous key without rotation",
+			body:      webhookBody,
+			signature: http.ComputeSignature("previous", webhookBody),
+			merchant:  &Merchant{SecretKey: "current"},
+			wantErr:   ErrWebhookSignatureInvalid,
+		},
+		{
+			name:      "tampered body",
+			body:      `{"action":"payment","order_id":"order-1","details":{"amount":1000.50,"payment_id":42}}`,
+			signature: http.ComputeSignature("current", webhookBody),
+			merchant:  merchant,
+			wantErr:   ErrWebhookSignatureInvalid,
+		},
+		{name: "unknown key", body: webhookBody, signature: http.ComputeSignature("unknown", webhookBody), merchant: merchant, wantErr: ErrWebhookSignatureInvalid},
+		{name: "missing signature", body: webhookBody, signature: "", merchant: merchant, wantErr: ErrWebhookSignatureMissing},
+		{name: "blank signature", body: webhookBody, signature: "  ", merchant: merchant, wantErr: ErrWebhookSignatureMissing},
+		{name: "nil merchant", body: webhookBody, signature: http.ComputeSignature("current", webhookBody), merchant: nil, wantErr: ErrMerchantIsNil},
+	}
+
+	for _, tt := range tests {
+		err := VerifyWebhook([]byte(tt.body), tt.signature, tt.merchant)
+		if tt.wantErr == nil && err != nil {
+			t.Errorf("%s: VerifyWebhook() error = %v, want nil", tt.name, err)
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: VerifyWebhook() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseVerifiedWebhook(t *testing.T) {
+	merchant := &Merchant{SecretKey: "current", PreviousSecretKeys: []string{"previous"}}
+
+	webhook, err := ParseVerifiedWebhook([]byte(webhookBody), http.ComputeSignature("previous", webhookBody), merchant)
+	if err != nil {
+		t.Fatalf("ParseVerifiedWebhook() error = %v", err)
+	}
+	if webhook.OrderId != "order-1" || webhook.Action != "payment" {
+		t.Fatalf("ParseVerifiedWebhook() = %+v, want order-1 payment", webhook)
+	}
+	if webhook.Details == nil || webhook.Details.PaymentId != 42 {
+		t.Fatalf("ParseVerifiedWebhook() details = %+v, want payment 42", webhook.Details)
+	}
+
+	tampered := []byte(`{"action":"payment","order_id":"order-2"}`)
+	if _, err := ParseVerifiedWebhook(tampered, http.ComputeSignature("current", webhookBody), merchant); !errors.Is(err, ErrWebhookSignatureInvalid) {
+		t.Fatalf("ParseVerifiedWebhook() tampered error = %v, want %v", err, ErrWebhookSignatureInvalid)
+	}
+
+	if _, err := ParseVerifiedWebhook([]byte(webhookBody), "", merchant); !errors.Is(err, ErrWebhookSignatureMissing) {
+		t.Fatalf("ParseVerifiedWebhook() unsigned error = %v, want %v", err, ErrWebhookSignatureMissing)
+	}
+
+	invalid := `{"action":`
+	if _, err := ParseVerifiedWebhook([]byte(invalid), http.ComputeSignature("current", invalid), merchant); err == nil {
+		t.Fatal("ParseVerifiedWebhook() accepted a body that is not JSON")
+	}
+}